@@ -15,6 +15,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -32,17 +33,63 @@ var (
 		Short:  "Check console output for badness.",
 		Long: `
 Check console output for expressions matching failure messages logged
-by a Container Linux instance.
+by a Container Linux instance. Rules loaded with --console-rules are
+checked as well.
 
 If no files are specified as arguments, stdin is checked.
+`}
+
+	cmdConsole = &cobra.Command{
+		Use:    "console <machine>",
+		Run:    runConsole,
+		PreRun: preRun,
+		Short:  "Print the console output of a machine left running by kola",
+		Long: `Print the console output of a machine recorded in the state file,
+given by its ID or its name in a spawn spec.
+
+The platform options must give access to the account and region the
+machine was created in.
 `}
 
 	checkConsoleVerbose bool
+	checkConsoleJSON    bool
 )
 
+// consoleProblem is a problem found in a file, as printed by --json.
+type consoleProblem struct {
+	File string `json:"file"`
+	kola.ConsoleProblem
+}
+
 func init() {
 	cmdCheckConsole.Flags().BoolVarP(&checkConsoleVerbose, "verbose", "v", false, "output user input prompts")
+	cmdCheckConsole.Flags().BoolVar(&checkConsoleJSON, "json", false, "print the problems found as a JSON list")
 	root.AddCommand(cmdCheckConsole)
+	root.AddCommand(cmdConsole)
+}
+
+func runConsole(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Expecting a machine\n")
+		os.Exit(2)
+	}
+
+	state, err := kola.ReadState(kola.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	c, m := state.Machine(args[0])
+	if m == nil {
+		fmt.Fprintf(os.Stderr, "Error: no machine %q in %v\n", args[0], kola.StateFile)
+		os.Exit(1)
+	}
+	console, err := kola.MachineConsole(*c, *m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting the console of %v: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	fmt.Print(console)
 }
 
 func runCheckConsole(cmd *cobra.Command, args []string) {
@@ -52,6 +99,7 @@ func runCheckConsole(cmd *cobra.Command, args []string) {
 	}
 
 	errors := 0
+	problems := []consoleProblem{}
 	for _, arg := range args {
 		var console []byte
 		var err error
@@ -70,9 +118,25 @@ func runCheckConsole(cmd *cobra.Command, args []string) {
 			errors += 1
 			continue
 		}
-		for _, badness := range kola.CheckConsole(console, nil) {
-			fmt.Printf("%v: %v\n", sourceName, badness)
-			errors += 1
+		for _, p := range kola.FindConsoleProblems(console, nil) {
+			if checkConsoleJSON {
+				problems = append(problems, consoleProblem{File: sourceName, ConsoleProblem: p})
+			} else if p.Severity == kola.SeverityWarning {
+				fmt.Printf("%v: %v (warning)\n", sourceName, p)
+			} else {
+				fmt.Printf("%v: %v\n", sourceName, p)
+			}
+			if p.Severity != kola.SeverityWarning {
+				errors += 1
+			}
+		}
+	}
+	if checkConsoleJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(problems); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
 		}
 	}
 	if errors > 0 {
@@ -0,0 +1,94 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/kola"
+)
+
+var (
+	cmdDestroy = &cobra.Command{
+		Use:   "destroy [cluster name...]",
+		Short: "Destroy clusters left running by kola",
+		Long: `Destroy clusters recorded in the state file, the clusters of failed
+tests kept by kola run --keep-failed and those left running by kola
+spawn --detach. See kola ps.
+
+The platform options must give access to the account and region the
+clusters were created in.
+`,
+		Run:    runDestroy,
+		PreRun: preRun,
+	}
+
+	destroyAll bool
+)
+
+func init() {
+	cmdDestroy.Flags().BoolVar(&destroyAll, "all", false, "destroy all clusters in the state file")
+	root.AddCommand(cmdDestroy)
+}
+
+func runDestroy(cmd *cobra.Command, args []string) {
+	if destroyAll == (len(args) > 0) {
+		fmt.Fprintf(os.Stderr, "Expecting cluster names or --all\n")
+		os.Exit(2)
+	}
+
+	state, err := kola.ReadState(kola.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var clusters []kola.ClusterState
+	if destroyAll {
+		clusters = state.Clusters
+	} else {
+		for _, name := range args {
+			c := state.Cluster(name)
+			if c == nil {
+				fmt.Fprintf(os.Stderr, "Error: no cluster %q in %v\n", name, kola.StateFile)
+				os.Exit(1)
+			}
+			clusters = append(clusters, *c)
+		}
+	}
+
+	failed := false
+	for _, c := range clusters {
+		if err := kola.DestroyCluster(c); err != nil {
+			fmt.Fprintf(os.Stderr, "Error destroying cluster %v on %v: %v\n", c.Name, c.Platform, err)
+			failed = true
+			continue
+		}
+		err := kola.UpdateState(kola.StateFile, func(s *kola.State) error {
+			s.Remove(c.Name)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Destroyed cluster %v (%v, %v)\n", c.Name, c.Platform, c.Test)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/kola"
+)
+
+var (
+	cmdDiff = &cobra.Command{
+		Use:   "diff old/report.json new/report.json",
+		Short: "Compare the results of two kola runs",
+		Long: `Compare the report.json files of two kola runs.
+
+Lists the tests which newly fail, newly pass, are newly skipped or are
+missing in the new run, passing tests whose duration changed a lot and
+metrics recorded by the tests which changed a lot.
+Exits with status 1 if any test newly fails.
+`,
+		Run: runDiff,
+	}
+
+	diffThreshold float64
+)
+
+func init() {
+	cmdDiff.Flags().Float64Var(&diffThreshold, "duration-threshold", 0.5, "report duration and metric changes larger than this fraction of the old value")
+	root.AddCommand(cmdDiff)
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "Expecting 2 arguments, got %d\n", len(args))
+		os.Exit(2)
+	}
+
+	diff, err := kola.DiffReports(args[0], args[1], diffThreshold)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("old: %s\n", describeRun(diff.OldPlatform, diff.OldVersion, args[0]))
+	fmt.Printf("new: %s\n", describeRun(diff.NewPlatform, diff.NewVersion, args[1]))
+
+	printChanges := func(title string, changes []kola.TestChange, describe func(kola.TestChange) string) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		for _, c := range changes {
+			fmt.Printf("    %s%s\n", c.Name, describe(c))
+		}
+	}
+	results := func(c kola.TestChange) string {
+		if c.OldResult == "" {
+			return fmt.Sprintf(" (new test, %s)", c.NewResult)
+		}
+		return fmt.Sprintf(" (%s -> %s)", c.OldResult, c.NewResult)
+	}
+	printChanges("Newly failing", diff.NewlyFailing, results)
+	printChanges("Newly passing", diff.NewlyPassing, results)
+	printChanges("Newly skipped", diff.NewlySkipped, results)
+	printChanges("Missing", diff.Missing, func(c kola.TestChange) string {
+		return fmt.Sprintf(" (was %s)", c.OldResult)
+	})
+	printChanges("Duration changed", diff.DurationChanged, func(c kola.TestChange) string {
+		change := 100 * (c.NewDuration.Seconds() - c.OldDuration.Seconds()) / c.OldDuration.Seconds()
+		return fmt.Sprintf(": %v -> %v (%+.0f%%)", c.OldDuration.Round(time.Second), c.NewDuration.Round(time.Second), change)
+	})
+	if len(diff.MetricChanged) > 0 {
+		fmt.Printf("\nMetrics changed:\n")
+		for _, m := range diff.MetricChanged {
+			change := 100 * (m.New - m.Old) / math.Abs(m.Old)
+			fmt.Printf("    %s %s: %v -> %v %s(%+.0f%%)\n", m.Test, m.Name, m.Old, m.New, unitSuffix(m.Unit), change)
+		}
+	}
+
+	if diff.Regressed() {
+		fmt.Printf("\nFAIL, %d tests newly failing\n", len(diff.NewlyFailing))
+		os.Exit(1)
+	}
+}
+
+// unitSuffix returns unit followed by a space, if there is a unit.
+func unitSuffix(unit string) string {
+	if unit == "" {
+		return ""
+	}
+	return unit + " "
+}
+
+// describeRun returns a short description of the run of a report.
+func describeRun(platform, version, file string) string {
+	if platform == "" {
+		platform = "unknown platform"
+	}
+	if version == "" {
+		version = "unknown version"
+	}
+	return fmt.Sprintf("%s %s (%s)", platform, version, file)
+}
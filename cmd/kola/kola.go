@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"golang.org/x/crypto/ssh/agent"
@@ -67,9 +68,14 @@ will be ignored.
 	listJSON   bool
 	listFilter bool
 
+	externalTests string
+
 	runRemove     bool
 	runSetSSHKeys bool
 	runSSHKeys    []string
+	runShard      string
+	runRerun      string
+	runRerunSkip  bool
 )
 
 func init() {
@@ -78,10 +84,24 @@ func init() {
 
 	cmdList.Flags().BoolVar(&listJSON, "json", false, "format output in JSON")
 	cmdList.Flags().BoolVar(&listFilter, "filter", false, "Filter by --platform and --distro, required for glob patterns, uses '*' as pattern if no pattern is specified")
+	cmdList.Flags().StringVar(&kola.TagFilter, "tags", "", "only list tests whose tags match this expression, e.g. 'storage && !slow'")
+	cmdList.Flags().StringVar(&externalTests, "external-tests", "", "also list the script tests in this directory")
 
 	cmdRun.Flags().BoolVarP(&runRemove, "remove", "r", true, "remove instances after test exits (--remove=false will keep them)")
+	cmdRun.Flags().BoolVar(&kola.KeepFailed, "keep-failed", false, "leave the instances of failed tests running and record them in --state-file for kola destroy")
 	cmdRun.Flags().BoolVarP(&runSetSSHKeys, "keys", "k", false, "add SSH keys from --key options")
 	cmdRun.Flags().StringSliceVar(&runSSHKeys, "key", nil, "path to SSH public key (default: SSH agent + ~/.ssh/id_{rsa,dsa,ecdsa,ed25519}.pub)")
+	cmdRun.Flags().StringVar(&externalTests, "external-tests", "", "also run the script tests in this directory, each described by a YAML or JSON metadata file")
+	cmdRun.Flags().StringVar(&kola.TagFilter, "tags", "", "only run tests whose tags match this expression, e.g. 'storage && !slow'")
+	cmdRun.Flags().StringVar(&kola.DenylistFile, "denylist", "", "skip the known broken tests listed in this YAML or JSON file")
+	cmdRun.Flags().IntVar(&kola.TestRetries, "retry", 0, "re-run failed tests up to this many times, reporting tests which pass on a retry as flaky")
+	cmdRun.Flags().StringSliceVar(&kola.ReportFormats, "report", []string{"json"}, "reports to write to the reports directory: json (report.json), junit (report.xml), html (report.html)")
+	cmdRun.Flags().BoolVar(&kola.IsolatePanics, "isolate-panics", true, "fail only the test which panics instead of aborting the run (--isolate-panics=false aborts)")
+	cmdRun.Flags().StringVar(&kola.EventsFile, "events", "", "stream test events to this file as JSON lines like go test -json, - for stdout")
+	cmdRun.Flags().StringVar(&runRerun, "rerun-failed", "", "only run the tests which failed in this report.json of a previous run")
+	cmdRun.Flags().BoolVar(&runRerunSkip, "rerun-skipped", false, "with --rerun-failed, also run the tests which were skipped")
+	cmdRun.Flags().StringVar(&runShard, "shard", "", "only run shard i of n of the selected tests, given as i/n")
+	cmdRun.Flags().StringSliceVar(&kola.DurationsFiles, "durations", nil, "report.json files of previous runs used to start the longest tests first and balance --shard (default: report of the last run in the default --output-dir, for ordering only)")
 
 }
 
@@ -90,8 +110,7 @@ func main() {
 }
 
 func preRun(cmd *cobra.Command, args []string) {
-	err := syncOptions()
-	if err != nil {
+	if err := syncOptions(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(3)
 	}
@@ -106,6 +125,8 @@ func preRun(cmd *cobra.Command, args []string) {
 }
 
 func runRun(cmd *cobra.Command, args []string) {
+	registerExternalTests()
+
 	var patterns []string
 	if len(args) >= 1 {
 		patterns = args
@@ -113,8 +134,55 @@ func runRun(cmd *cobra.Command, args []string) {
 		patterns = []string{"*"} // run all tests by default
 	}
 
+	if err := kola.CheckReportFormats(kola.ReportFormats); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(3)
+	}
+
 	var err error
-	outputDir, err = kola.SetupOutputDir(outputDir, kolaPlatform)
+	if runRerun != "" {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: --rerun-failed cannot be combined with glob patterns\n")
+			os.Exit(3)
+		}
+		// resolve e.g. the qemu-latest symlink, it moves on this run
+		kola.RerunOf, err = filepath.EvalSymlinks(runRerun)
+		if err == nil {
+			kola.RerunOf, err = filepath.Abs(kola.RerunOf)
+		}
+		if err == nil {
+			patterns, err = kola.RerunPatterns(kola.RerunOf, runRerunSkip)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: reading %v: %v\n", runRerun, err)
+			os.Exit(3)
+		}
+		if len(patterns) == 0 {
+			fmt.Printf("No tests to re-run in %v\n", runRerun)
+			return
+		}
+	}
+	if runShard != "" {
+		kola.ShardIndex, kola.ShardCount, err = kola.ParseShard(runShard)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(3)
+		}
+	}
+
+	platforms := strings.Split(kolaPlatform, ",")
+	if len(kola.DurationsFiles) == 0 && outputDir == "" {
+		if report := kola.PreviousReport(strings.Join(platforms, "+")); report != "" {
+			kola.PreviousReportFile = report
+		}
+	}
+	if kola.KeepFailed {
+		if err := checkKeepFailed(platforms); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(3)
+		}
+	}
+	outputDir, err = kola.SetupOutputDir(outputDir, strings.Join(platforms, "+"))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
@@ -130,7 +198,7 @@ func runRun(cmd *cobra.Command, args []string) {
 	} else {
 		sshKeys = nil
 	}
-	runErr := kola.RunTests(patterns, kolaChannel, kolaOffering, kolaPlatform, outputDir, &sshKeys, runRemove)
+	runErr := kola.RunTests(patterns, kolaChannel, kolaOffering, platforms, outputDir, &sshKeys, runRemove)
 
 	// needs to be after RunTests() because harness empties the directory
 	if err := writeProps(); err != nil {
@@ -144,6 +212,35 @@ func runRun(cmd *cobra.Command, args []string) {
 	}
 }
 
+// registerExternalTests registers the tests in the --external-tests
+// directory, if any.
+func registerExternalTests() {
+	if externalTests == "" {
+		return
+	}
+	if err := kola.RegisterExternalTests(externalTests); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: loading external tests: %v\n", err)
+		os.Exit(3)
+	}
+}
+
+// checkKeepFailed returns an error if instances on one of the platforms
+// cannot outlive kola.
+func checkKeepFailed(platforms []string) error {
+	if kola.StateFile == "" {
+		return fmt.Errorf("--keep-failed requires --state-file")
+	}
+	for _, pltfrm := range platforms {
+		switch {
+		case pltfrm == "qemu":
+			return fmt.Errorf("--keep-failed is not supported on qemu, its network is torn down when kola exits; use qemu-unpriv")
+		case pltfrm == "azure" && kola.AzureOptions.BlobURL != "":
+			return fmt.Errorf("--keep-failed cannot be combined with --azure-blob-url, the image resource group holding the instances is deleted when kola exits")
+		}
+	}
+	return nil
+}
+
 func writeProps() error {
 	f, err := os.OpenFile(filepath.Join(outputDir, "properties.json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	if err != nil {
@@ -268,6 +365,7 @@ func writeProps() error {
 }
 
 func runList(cmd *cobra.Command, args []string) {
+	registerExternalTests()
 	tests := register.Tests
 
 	if listFilter {
@@ -277,12 +375,30 @@ func runList(cmd *cobra.Command, args []string) {
 		} else {
 			patterns = []string{"*"} // run all tests by default
 		}
-		var err error
-		tests, err = kola.FilterTests(register.Tests, patterns, kolaChannel, kolaOffering, kolaPlatform, semver.Version{})
+		// list the tests of any of the platforms
+		tests = make(map[string]*register.Test)
+		for _, pltfrm := range strings.Split(kolaPlatform, ",") {
+			filtered, err := kola.FilterTests(register.Tests, patterns, kolaChannel, kolaOffering, pltfrm, semver.Version{})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "filtering error: %v\n", err)
+				os.Exit(1)
+			}
+			for name, test := range filtered {
+				tests[name] = test
+			}
+		}
+	} else if kola.TagFilter != "" {
+		tagExpr, err := kola.ParseTagExpr(kola.TagFilter)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "filtering error: %v\n", err)
 			os.Exit(1)
 		}
+		tests = make(map[string]*register.Test)
+		for name, test := range register.Tests {
+			if tagExpr.Match(test.Tags) {
+				tests[name] = test
+			}
+		}
 	}
 
 	var testlist []*item
@@ -299,6 +415,7 @@ func runList(cmd *cobra.Command, args []string) {
 			test.ExcludeChannels,
 			test.Offerings,
 			test.ExcludeOfferings,
+			test.Tags,
 		}
 		item.updateValues()
 		testlist = append(testlist, item)
@@ -311,8 +428,8 @@ func runList(cmd *cobra.Command, args []string) {
 	if !listJSON {
 		var w = tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
 
-		fmt.Fprintln(w, "Test Name\tPlatforms\tArchitectures\tDistributions\tChannels\tOfferings")
-		fmt.Fprintln(w, "\t\t\t\t\t")
+		fmt.Fprintln(w, "Test Name\tPlatforms\tArchitectures\tDistributions\tChannels\tOfferings\tTags")
+		fmt.Fprintln(w, "\t\t\t\t\t\t")
 		for _, item := range testlist {
 			fmt.Fprintf(w, "%v\n", item)
 		}
@@ -338,6 +455,7 @@ type item struct {
 	ExcludeChannels  []string `json:"-"`
 	Offerings        []string
 	ExcludeOfferings []string `json:"-"`
+	Tags             []string
 }
 
 func (i *item) updateValues() {
@@ -377,8 +495,11 @@ func (i *item) updateValues() {
 	i.Distros = buildItems(i.Distros, i.ExcludeDistros, kolaDistros)
 	i.Channels = buildItems(i.Channels, i.ExcludeChannels, kolaChannels)
 	i.Offerings = buildItems(i.Offerings, i.ExcludeOfferings, kolaOfferings)
+	if i.Tags == nil {
+		i.Tags = []string{}
+	}
 }
 
 func (i item) String() string {
-	return fmt.Sprintf("%v\t%v\t%v\t%v\t%v\t%v", i.Name, i.Platforms, i.Architectures, i.Distros, i.Channels, i.Offerings)
+	return fmt.Sprintf("%v\t%v\t%v\t%v\t%v\t%v\t%v", i.Name, i.Platforms, i.Architectures, i.Distros, i.Channels, i.Offerings, i.Tags)
 }
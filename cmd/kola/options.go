@@ -21,8 +21,10 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 
@@ -34,6 +36,9 @@ import (
 
 var (
 	outputDir          string
+	consoleRules       string
+	configFile         string
+	profileName        string
 	kolaPlatform       string
 	kolaChannel        string
 	kolaOffering       string
@@ -65,9 +70,11 @@ func init() {
 	ss := root.PersistentFlags().StringSlice
 
 	// general options
+	sv(&configFile, "config", "kola.yaml", "YAML or JSON file of named profiles of flags")
+	sv(&profileName, "profile", "", "set the flags of this profile of the config file, flags given on the command line take precedence")
 	sv(&outputDir, "output-dir", "", "Temporary output directory for test data and logs")
 	sv(&kola.TorcxManifestFile, "torcx-manifest", "", "Path to a torcx manifest that should be made available to tests")
-	root.PersistentFlags().StringVarP(&kolaPlatform, "platform", "p", "qemu", "VM platform: "+strings.Join(kolaPlatforms, ", "))
+	root.PersistentFlags().StringVarP(&kolaPlatform, "platform", "p", "qemu", "VM platform: "+strings.Join(kolaPlatforms, ", ")+"; kola run and list accept several separated by commas")
 	root.PersistentFlags().StringVarP(&kolaChannel, "channel", "", "stable", "Channel: "+strings.Join(kolaChannels, ", "))
 	root.PersistentFlags().StringVarP(&kolaOffering, "offering", "", "basic", "Offering: "+strings.Join(kolaOfferings, ", "))
 	root.PersistentFlags().StringVarP(&kola.Options.Distribution, "distro", "b", "cl", "Distribution: "+strings.Join(kolaDistros, ", "))
@@ -77,6 +84,8 @@ func init() {
 	ss("debug-systemd-unit", []string{}, "full-unit-name.service to enable SYSTEMD_LOG_LEVEL=debug on. Specify multiple times for multiple units.")
 	sv(&kola.UpdatePayloadFile, "update-payload", "", "Path to an update payload that should be made available to tests")
 	sv(&kola.Options.IgnitionVersion, "ignition-version", "", "Ignition version override: v2, v3")
+	sv(&consoleRules, "console-rules", "", "YAML or JSON file of console rules to check in addition to the built-in ones")
+	sv(&kola.StateFile, "state-file", defaultStateFile(), "file recording the instances left running by kola run --keep-failed and kola spawn --detach")
 
 	// rhcos-specific options
 	sv(&kola.Options.OSContainer, "oscontainer", "", "oscontainer image pullspec for pivot (RHCOS only)")
@@ -166,6 +175,71 @@ func init() {
 	sv(&kola.QEMUOptions.DiskImage, "qemu-image", "", "path to CoreOS disk image")
 	sv(&kola.QEMUOptions.BIOSImage, "qemu-bios", "", "BIOS to use for QEMU vm")
 	bv(&kola.QEMUOptions.UseVanillaImage, "qemu-skip-mangle", false, "don't modify CL disk image to capture console log")
+
+	root.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := applyProfile(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(3)
+		}
+	}
+}
+
+// applyProfile sets the flags of cmd which the selected profile of the
+// config file gives values to, unless they are on the command line. The
+// flags of other kola commands are ignored, so that a profile can be used
+// by every kola command. It runs before the PreRun of any command, but
+// after logging was started, so the log flags cannot be set in a profile.
+func applyProfile(cmd *cobra.Command) error {
+	if profileName == "" {
+		return nil
+	}
+	profile, err := kola.ReadProfile(configFile, profileName)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range profile {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch name {
+		case "config", "profile", "log-level", "verbose", "debug":
+			// the profile is read after these took effect
+			return fmt.Errorf("%v: profile %v: %v cannot be set in a profile", configFile, profileName, name)
+		}
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			if !hasFlag(root, name) {
+				return fmt.Errorf("%v: profile %v: unknown flag %v", configFile, profileName, name)
+			}
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+		for _, value := range profile[name] {
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return fmt.Errorf("%v: profile %v: %v: %v", configFile, profileName, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// hasFlag reports whether cmd or any of its subcommands has the named
+// flag.
+func hasFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, c := range cmd.Commands() {
+		if hasFlag(c, name) {
+			return true
+		}
+	}
+	return false
 }
 
 // Sync up the command line options if there is dependency
@@ -193,8 +267,10 @@ func syncOptions() error {
 		return fmt.Errorf("unsupported %v %q", name, item)
 	}
 
-	if err := validateOption("platform", kolaPlatform, kolaPlatforms); err != nil {
-		return err
+	for _, pltfrm := range strings.Split(kolaPlatform, ",") {
+		if err := validateOption("platform", pltfrm, kolaPlatforms); err != nil {
+			return err
+		}
 	}
 
 	if err := validateOption("channel", kolaChannel, kolaChannels); err != nil {
@@ -234,6 +310,12 @@ func syncOptions() error {
 		return fmt.Errorf("oscontainer is only supported on rhcos")
 	}
 
+	if consoleRules != "" {
+		if err := kola.LoadConsoleRules(consoleRules); err != nil {
+			return err
+		}
+	}
+
 	if kola.Options.IgnitionVersion == "" {
 		kola.Options.IgnitionVersion, ok = kolaIgnitionVersionDefaults[kola.Options.Distribution]
 		if !ok {
@@ -244,6 +326,16 @@ func syncOptions() error {
 	return nil
 }
 
+// defaultStateFile returns ~/.kola/state.json, or "" if there is no home
+// directory.
+func defaultStateFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kola", "state.json")
+}
+
 func GetSSHKeys(sshKeys []string) ([]agent.Key, error) {
 	var allKeys []agent.Key
 	// if no keys specified, use keys from agent plus ~/.ssh/id_{rsa,dsa,ecdsa,ed25519}.pub
@@ -0,0 +1,139 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/mantle/kola"
+)
+
+var (
+	cmdPs = &cobra.Command{
+		Use:   "ps",
+		Short: "List clusters left running by kola",
+		Long: `List the clusters recorded in the state file, left running by
+kola spawn --detach (or --remove=false) and kola run --keep-failed.
+`,
+		Run: runPs,
+	}
+
+	cmdSSH = &cobra.Command{
+		Use:   "ssh <machine> [-- command...]",
+		Short: "Log in to a machine left running by kola",
+		Long: `Log in to a machine recorded in the state file, given by its ID or
+its name in a spawn spec, with ssh as the core user.
+
+Since kola's own SSH key is gone once it exits, the machine must have
+been created with -k to accept your keys.
+`,
+		Run: runSSH,
+	}
+
+	psJSON bool
+)
+
+func init() {
+	cmdPs.Flags().BoolVar(&psJSON, "json", false, "format output in JSON")
+	root.AddCommand(cmdPs)
+	root.AddCommand(cmdSSH)
+}
+
+func runPs(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "Extra arguments specified\n")
+		os.Exit(2)
+	}
+
+	state, err := kola.ReadState(kola.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if psJSON {
+		out, err := json.MarshalIndent(state.Clusters, "", "\t")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshalling clusters: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Cluster/Machine\tPlatform\tCreated\tOrigin/IP\tPrivate IP")
+	for _, c := range state.Clusters {
+		origin := c.Test
+		if origin == "" {
+			origin = "spawn"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", c.Name, c.Platform, c.Created.Local().Format("2006-01-02 15:04"), origin)
+		for _, m := range c.Machines {
+			name := m.ID
+			if m.Name != "" {
+				name = fmt.Sprintf("%v (%v)", m.Name, m.ID)
+			}
+			fmt.Fprintf(w, "  %v\t\t\t%v\t%v\n", name, m.IP, m.PrivateIP)
+		}
+	}
+	w.Flush()
+}
+
+func runSSH(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Expecting a machine\n")
+		os.Exit(2)
+	}
+
+	state, err := kola.ReadState(kola.StateFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	_, m := state.Machine(args[0])
+	if m == nil {
+		fmt.Fprintf(os.Stderr, "Error: no machine %q in %v\n", args[0], kola.StateFile)
+		os.Exit(1)
+	}
+
+	ssh, err := exec.LookPath("ssh")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// the host keys of the machines are new every time
+	sshArgs := []string{"ssh", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null"}
+	host, port, err := net.SplitHostPort(m.IP)
+	if err != nil {
+		host = m.IP
+	} else {
+		sshArgs = append(sshArgs, "-p", port)
+	}
+	sshArgs = append(sshArgs, "core@"+host)
+	sshArgs = append(sshArgs, args[1:]...)
+
+	err = syscall.Exec(ssh, sshArgs, os.Environ())
+	fmt.Fprintf(os.Stderr, "Error: running ssh: %v\n", err)
+	os.Exit(1)
+}
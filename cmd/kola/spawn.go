@@ -18,12 +18,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/agent"
 
 	"github.com/coreos/mantle/kola"
 	"github.com/coreos/mantle/platform"
@@ -51,6 +53,7 @@ var (
 	spawnMachineOptions string
 	spawnSetSSHKeys     bool
 	spawnSSHKeys        []string
+	spawnSpec           string
 )
 
 func init() {
@@ -61,9 +64,10 @@ func init() {
 	cmdSpawn.Flags().BoolVarP(&spawnShell, "shell", "s", true, "spawn a shell in an instance before exiting")
 	cmdSpawn.Flags().BoolVarP(&spawnRemove, "remove", "r", true, "remove instances after shell exits")
 	cmdSpawn.Flags().BoolVarP(&spawnVerbose, "verbose", "v", false, "output information about spawned instances")
-	cmdSpawn.Flags().StringVar(&spawnMachineOptions, "qemu-options", "", "experimental: path to QEMU machine options json")
+	cmdSpawn.Flags().StringVar(&spawnMachineOptions, "qemu-options", "", "experimental: path to machine options json")
 	cmdSpawn.Flags().BoolVarP(&spawnSetSSHKeys, "keys", "k", false, "add SSH keys from --key options")
 	cmdSpawn.Flags().StringSliceVar(&spawnSSHKeys, "key", nil, "path to SSH public key (default: SSH agent + ~/.ssh/id_{rsa,dsa,ecdsa,ed25519}.pub)")
+	cmdSpawn.Flags().StringVar(&spawnSpec, "spec", "", "YAML or JSON file describing the machines to spawn, instead of --nodecount, --userdata and --qemu-options")
 	root.AddCommand(cmdSpawn)
 }
 
@@ -77,6 +81,9 @@ func runSpawn(cmd *cobra.Command, args []string) {
 func doSpawn(cmd *cobra.Command, args []string) error {
 	var err error
 
+	if spawnDetach && kolaPlatform == "qemu" {
+		fmt.Fprintf(os.Stderr, "Warning: the machines of --detach are unreachable on qemu, their network is torn down when kola exits; use qemu-unpriv\n")
+	}
 	if spawnDetach {
 		spawnSetSSHKeys = true
 		spawnVerbose = true
@@ -88,28 +95,49 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Cluster Failed: nodecount must be one or more")
 	}
 
-	var userdata *conf.UserData
-	if spawnUserData != "" {
-		userbytes, err := ioutil.ReadFile(spawnUserData)
+	var spec *kola.ClusterSpec
+	if spawnSpec != "" {
+		for _, flag := range []string{"nodecount", "userdata", "qemu-options"} {
+			if cmd.Flags().Changed(flag) {
+				return fmt.Errorf("--%s cannot be used with --spec", flag)
+			}
+		}
+		spec, err = kola.ReadClusterSpec(spawnSpec)
 		if err != nil {
-			return fmt.Errorf("Reading userdata failed: %v", err)
+			return fmt.Errorf("Reading cluster spec failed: %v", err)
 		}
-		userdata = conf.Unknown(string(userbytes))
 	}
+
+	var sshKeys []agent.Key
 	if spawnSetSSHKeys {
-		if userdata == nil {
-			userdata = conf.Ignition(`{"ignition": {"version": "2.0.0"}}`)
-		}
-		sshKeys, err := GetSSHKeys(spawnSSHKeys)
+		sshKeys, err = GetSSHKeys(spawnSSHKeys)
 		if err != nil {
 			return err
 		}
-		// If the user explicitly passed empty userdata, the userdata
-		// will be non-nil but Empty, and adding SSH keys will
-		// silently fail.
-		userdata = conf.AddSSHKeys(userdata, &sshKeys)
+	}
+	prepareUserData := func(userdata *conf.UserData) *conf.UserData {
+		if spawnSetSSHKeys {
+			if userdata == nil {
+				userdata = conf.Ignition(`{"ignition": {"version": "2.0.0"}}`)
+			}
+			// If the user explicitly passed empty userdata, the userdata
+			// will be non-nil but Empty, and adding SSH keys will
+			// silently fail.
+			userdata = conf.AddSSHKeys(userdata, &sshKeys)
+		}
+		return userdata
 	}
 
+	var userdata *conf.UserData
+	if spawnUserData != "" {
+		userbytes, err := ioutil.ReadFile(spawnUserData)
+		if err != nil {
+			return fmt.Errorf("Reading userdata failed: %v", err)
+		}
+		userdata = conf.Unknown(string(userbytes))
+	}
+	userdata = prepareUserData(userdata)
+
 	outputDir, err = kola.SetupOutputDir(outputDir, kolaPlatform)
 	if err != nil {
 		return fmt.Errorf("Setup failed: %v", err)
@@ -157,14 +185,46 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 		updateConf = strings.NewReader(fmt.Sprintf("GROUP=developer\nSERVER=http://%s/v1/update/\n", hostport))
 	}
 
+	setupMachine := func(mach platform.Machine) error {
+		if updateConf != nil {
+			if _, err := updateConf.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := platform.InstallFile(updateConf, mach, "/etc/coreos/update.conf"); err != nil {
+				return fmt.Errorf("Setting update.conf: %v", err)
+			}
+		}
+		return nil
+	}
+
+	names := make(map[string]string) // machine IDs to names from the spec
 	var someMach platform.Machine
-	for i := 0; i < spawnNodeCount; i++ {
+	if spec != nil {
+		if spawnVerbose {
+			fmt.Println("Spawning machines...")
+		}
+		machines, err := spec.Spawn(cluster, prepareUserData)
+		if err != nil {
+			return fmt.Errorf("Spawning instances failed: %v", err)
+		}
+		for _, mach := range machines {
+			if err := setupMachine(mach); err != nil {
+				return err
+			}
+			names[mach.ID()] = mach.Name
+			if spawnVerbose {
+				fmt.Printf("Machine %v (%v) spawned at %v\n", mach.Name, mach.ID(), mach.IP())
+			}
+		}
+		someMach = machines[0]
+	}
+	for i := 0; spec == nil && i < spawnNodeCount; i++ {
 		var mach platform.Machine
 		var err error
 		if spawnVerbose {
 			fmt.Println("Spawning machine...")
 		}
-		if kolaPlatform == "qemu" && spawnMachineOptions != "" {
+		if spawnMachineOptions != "" {
 			var b []byte
 			b, err = ioutil.ReadFile(spawnMachineOptions)
 			if err != nil {
@@ -177,17 +237,15 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("Could not unmarshal machine options: %v", err)
 			}
 
-			mach, err = cluster.(*qemu.Cluster).NewMachineWithOptions(userdata, machineOpts)
+			mach, err = cluster.NewMachineWithOptions(userdata, machineOpts)
 		} else {
 			mach, err = cluster.NewMachine(userdata)
 		}
 		if err != nil {
 			return fmt.Errorf("Spawning instance failed: %v", err)
 		}
-		if updateConf != nil {
-			if err := platform.InstallFile(updateConf, mach, "/etc/coreos/update.conf"); err != nil {
-				return fmt.Errorf("Setting update.conf: %v", err)
-			}
+		if err := setupMachine(mach); err != nil {
+			return err
 		}
 
 		if spawnVerbose {
@@ -197,6 +255,12 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 		someMach = mach
 	}
 
+	if !spawnRemove {
+		if err := recordSpawn(cluster, names); err != nil {
+			return err
+		}
+	}
+
 	if spawnShell {
 		if spawnRemove {
 			reader := strings.NewReader(`PS1="\[\033[0;31m\][bound]\[\033[0m\] $PS1"` + "\n")
@@ -210,3 +274,31 @@ func doSpawn(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// recordSpawn records a cluster left running in the state file, so it can
+// be found by kola ps and destroyed by kola destroy. qemu clusters cannot
+// be left running and are not recorded.
+func recordSpawn(cluster platform.Cluster, names map[string]string) error {
+	if kolaPlatform == "qemu" {
+		fmt.Fprintf(os.Stderr, "Warning: not recording cluster %v, the network of qemu machines is torn down when kola exits; use qemu-unpriv\n", cluster.Name())
+		return nil
+	}
+	cs, err := kola.NewClusterState(cluster, "", outputDir)
+	if err != nil {
+		return err
+	}
+	for i := range cs.Machines {
+		cs.Machines[i].Name = names[cs.Machines[i].ID]
+	}
+	err = kola.UpdateState(kola.StateFile, func(s *kola.State) error {
+		s.Clusters = append(s.Clusters, cs)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Recording cluster in %v: %v", kola.StateFile, err)
+	}
+	if spawnVerbose {
+		fmt.Printf("Cluster %v recorded in %v, remove it with: kola destroy %v\n", cs.Name, kola.StateFile, cs.Name)
+	}
+	return nil
+}
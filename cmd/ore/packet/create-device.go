@@ -23,6 +23,7 @@ import (
 	"github.com/spf13/cobra"
 
 	ctplatform "github.com/coreos/container-linux-config-transpiler/config/platform"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
@@ -71,7 +72,7 @@ func runCreateDevice(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
-	device, err := API.CreateDevice(hostname, conf, nil)
+	device, err := API.CreateDevice(hostname, conf, nil, platform.MachineOptions{})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Couldn't create device: %v\n", err)
 		os.Exit(1)
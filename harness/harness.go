@@ -27,8 +27,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coreos/mantle/harness/reporters"
@@ -46,7 +48,7 @@ import (
 // The other reporting methods, such as the variations of Log and Error,
 // may be called simultaneously from multiple goroutines.
 type H struct {
-	mu       sync.RWMutex // guards output, failed, and done.
+	mu       sync.RWMutex // guards output, failed, done, and metrics.
 	output   bytes.Buffer // Output generated by test.
 	w        io.Writer    // For flushToParent.
 	tap      io.Writer    // Optional TAP log of test results.
@@ -55,6 +57,7 @@ type H struct {
 	cancel   context.CancelFunc
 	ran      bool // Test (or one of its subtests) was executed.
 	failed   bool // Test has failed.
+	flaky    bool // Test has passed after failed attempts.
 	skipped  bool // Test has been skipped.
 	finished bool // Test function has completed.
 	done     bool // Test is finished and all subtests have completed.
@@ -67,10 +70,16 @@ type H struct {
 	start    time.Time // Time test started
 	duration time.Duration
 	barrier  chan bool // To signal parallel subtests they may start.
+	started  chan bool // Closed once a parallel test got to run.
 	signal   chan bool // To signal a test is done.
 	sub      []*H      // Queue of subtests to be run in parallel.
 
+	metrics []reporters.Metric // Measurements recorded by the test.
+
 	isParallel bool
+	isAttempt  bool  // Failures are not propagated to the parent.
+	isNonFatal bool  // Failures are reported as non-fatal.
+	abandoned  int32 // Set atomically once RunWithTimeout gave up on f.
 
 	reporters reporters.Reporters
 }
@@ -88,8 +97,12 @@ func (h *H) Verbose() bool {
 }
 
 func (c *H) status() testresult.TestResult {
-	if c.Failed() {
+	if c.Failed() && c.nonFatal() {
+		return testresult.NonFatal
+	} else if c.Failed() {
 		return testresult.Fail
+	} else if c.Flaky() {
+		return testresult.Flaky
 	} else if c.Skipped() {
 		return testresult.Skip
 	}
@@ -116,8 +129,12 @@ func (c *H) flushToParent(format string, args ...interface{}) {
 			rePassAfterFail := regexp.MustCompile(` *?--- PASS: .*?\n`)
 			msg := bytes.Trim(rePassAfterFail.ReplaceAll(rePassBeforeFail.ReplaceAll(c.output.Bytes(), []byte("--- FAIL")), nil), " \n")
 			fmt.Fprintf(p.tap, "not ok - %s\n  ---\n  Error: %q\n  ...\n", name, msg)
+		} else if status == testresult.NonFatal {
+			fmt.Fprintf(p.tap, "not ok - %s # TODO non-fatal failure\n", name)
 		} else if status == testresult.Skip {
 			fmt.Fprintf(p.tap, "ok - %s # SKIP\n", name)
+		} else if status == testresult.Flaky {
+			fmt.Fprintf(p.tap, "ok - %s # FLAKY\n", name)
 		} else {
 			fmt.Fprintf(p.tap, "ok - %s\n", name)
 		}
@@ -152,6 +169,20 @@ func (w indenter) Write(b []byte) (n int, err error) {
 	return
 }
 
+// eventOutput emits everything logged by a test as output events.
+type eventOutput struct {
+	c *H
+}
+
+func (w eventOutput) Write(b []byte) (int, error) {
+	w.c.suite.emit(reporters.Event{
+		Action: reporters.ActionOutput,
+		Test:   w.c.name,
+		Output: string(b),
+	})
+	return len(b), nil
+}
+
 // fmtDuration returns a string representing d in the form "87.00s".
 func fmtDuration(d time.Duration) string {
 	return fmt.Sprintf("%.2fs", d.Seconds())
@@ -182,7 +213,11 @@ func (c *H) setRan() {
 
 // Fail marks the function as having failed but continues execution.
 func (c *H) Fail() {
-	if c.parent != nil {
+	// The test has already failed for exceeding its deadline.
+	if c.isAbandoned() {
+		return
+	}
+	if c.parent != nil && !c.isAttempt {
 		c.parent.Fail()
 	}
 	c.mu.Lock()
@@ -194,6 +229,27 @@ func (c *H) Fail() {
 	c.failed = true
 }
 
+// nonFatal reports whether c or one of its parents was run by RunNonFatal.
+func (c *H) nonFatal() bool {
+	for ; c != nil; c = c.parent {
+		if c.isNonFatal {
+			return true
+		}
+	}
+	return false
+}
+
+// isAbandoned reports whether c or one of its parents is a function
+// RunWithTimeout gave up on. Whatever such a test reports is dropped.
+func (c *H) isAbandoned() bool {
+	for ; c != nil; c = c.parent {
+		if atomic.LoadInt32(&c.abandoned) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // Failed reports whether the function has failed.
 func (c *H) Failed() bool {
 	c.mu.RLock()
@@ -201,6 +257,19 @@ func (c *H) Failed() bool {
 	return c.failed
 }
 
+// Flaky reports whether the function has passed only after failed attempts.
+func (c *H) Flaky() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.flaky
+}
+
+func (c *H) setFlaky() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flaky = true
+}
+
 // FailNow marks the function as having failed and stops its execution.
 // Execution will continue at the next test.
 // FailNow must be called from the goroutine running the
@@ -235,6 +304,9 @@ func (c *H) FailNow() {
 
 // log generates the output. It's always at the same stack depth.
 func (c *H) log(s string) {
+	if c.isAbandoned() {
+		return
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.logger.Output(3, s)
@@ -300,6 +372,9 @@ func (c *H) SkipNow() {
 }
 
 func (c *H) skip() {
+	if c.isAbandoned() {
+		return
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.skipped = true
@@ -312,6 +387,36 @@ func (c *H) Skipped() bool {
 	return c.skipped
 }
 
+// RecordMetric records a measurement made by the test, such as a boot time,
+// to be included in the reports. Recording a metric of the same name again
+// replaces the value.
+func (c *H) RecordMetric(name string, value float64, unit string) {
+	if c.isAbandoned() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.metrics {
+		if c.metrics[i].Name == name {
+			c.metrics[i] = reporters.Metric{Name: name, Value: value, Unit: unit}
+			return
+		}
+	}
+	c.metrics = append(c.metrics, reporters.Metric{Name: name, Value: value, Unit: unit})
+}
+
+// recordedMetrics returns a copy of the metrics recorded by the test.
+func (c *H) recordedMetrics() []reporters.Metric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.metrics) == 0 {
+		return nil
+	}
+	metrics := make([]reporters.Metric, len(c.metrics))
+	copy(metrics, c.metrics)
+	return metrics
+}
+
 func (h *H) mkOutputDir() (dir string, err error) {
 	dir = h.suite.outputPath(h.name)
 	if err = os.MkdirAll(dir, 0777); err != nil {
@@ -378,17 +483,49 @@ func (t *H) Parallel() {
 	t.duration += time.Since(t.start)
 
 	// Add to the list of tests to be released by the parent.
+	var prev *H
+	if n := len(t.parent.sub); n > 0 {
+		prev = t.parent.sub[n-1]
+	}
 	t.parent.sub = append(t.parent.sub, t)
+	t.suite.emit(reporters.Event{Action: reporters.ActionPause, Test: t.name})
 
 	t.signal <- true   // Release calling test.
 	<-t.parent.barrier // Wait for the parent test to complete.
+	if prev != nil {
+		// Start in the order Parallel was called.
+		<-prev.started
+	}
+	t.suite.waitParallel()
+	close(t.started)
+	t.suite.emit(reporters.Event{Action: reporters.ActionCont, Test: t.name})
+	t.start = time.Now()
+}
+
+// Lock locks l. A parallel test, or a subtest of one, does not count
+// against the Parallel option of the suite while it waits for l, so tests
+// waiting for each other do not keep unrelated tests from running. Lock
+// must be called from the goroutine running the test function.
+func (t *H) Lock(l sync.Locker) {
+	parallel := false
+	for p := t; p != nil; p = p.parent {
+		parallel = parallel || p.isParallel
+	}
+	if !parallel {
+		l.Lock()
+		return
+	}
+	// Like in Parallel, the time spent waiting is not part of the test.
+	t.duration += time.Since(t.start)
+	t.suite.release()
+	l.Lock()
 	t.suite.waitParallel()
 	t.start = time.Now()
 }
 
 func tRunner(t *H, fn func(t *H)) {
 	t.ctx, t.cancel = context.WithCancel(t.parentContext())
-	defer t.cancel()
+	defer func() { t.cancel() }()
 
 	// When this goroutine is done, either because fn(t)
 	// returned normally or because a test failure triggered
@@ -401,7 +538,10 @@ func tRunner(t *H, fn func(t *H)) {
 		if !t.finished && err == nil {
 			err = fmt.Errorf("test executed panic(nil) or runtime.Goexit")
 		}
-		if err != nil {
+		if err != nil && t.suite.opts.IsolatePanics {
+			t.log(fmt.Sprintf("panic: %v\n\n%s", err, debug.Stack()))
+			t.Fail()
+		} else if err != nil {
 			t.Fail()
 			t.report()
 			panic(err)
@@ -445,24 +585,160 @@ func tRunner(t *H, fn func(t *H)) {
 // Run runs f as a subtest of t called name. It reports whether f succeeded.
 // Run will block until all its parallel subtests have completed.
 func (t *H) Run(name string, f func(t *H)) bool {
-	t.hasSub = true
-	testName, ok := t.suite.match.fullName(t, name)
-	if !ok {
+	sub := t.run(name, f, false, false)
+	return sub == nil || !sub.failed
+}
+
+// RunAttempts runs f as a subtest of t called "attempt-1" and, as long as
+// it fails, runs it again as "attempt-2" and so on until attempts subtests
+// have been run. A failed attempt does not fail t by itself: t fails only
+// if the last attempt fails and is reported as flaky if a later attempt
+// passes. It reports whether f eventually succeeded.
+// f must not call Parallel, the attempts are always run sequentially.
+func (t *H) RunAttempts(attempts int, f func(t *H)) bool {
+	for i := 1; i <= attempts; i++ {
+		sub := t.run(fmt.Sprintf("attempt-%d", i), f, true, false)
+		if sub == nil {
+			return true
+		}
+		if !sub.Failed() {
+			if sub.Skipped() {
+				t.skip()
+			} else if i > 1 {
+				t.setFlaky()
+			}
+			// The metrics of the passing attempt are those of the test.
+			for _, m := range sub.recordedMetrics() {
+				t.RecordMetric(m.Name, m.Value, m.Unit)
+			}
+			return true
+		}
+		if i < attempts {
+			t.Logf("attempt %d of %d failed, retrying", i, attempts)
+		}
+	}
+	t.Fail()
+	return false
+}
+
+// RunNonFatal runs f as a subtest of t called name whose failure does not
+// fail t. The subtest and its failed subtests are reported as NONFATAL
+// instead of FAIL. It reports whether f succeeded.
+// f must not call Parallel.
+func (t *H) RunNonFatal(name string, f func(t *H)) bool {
+	sub := t.run(name, f, true, true)
+	if sub == nil {
 		return true
 	}
-	t = &H{
-		barrier:   make(chan bool),
-		signal:    make(chan bool),
-		name:      testName,
+	if sub.Skipped() && !sub.Failed() {
+		t.skip()
+	}
+	return !sub.Failed()
+}
+
+// RunWithTimeout runs f until it returns or timeout has passed, reporting
+// whether it completed in time. f is passed an H standing in for t: it
+// logs to the output of t, failing it fails t, and its context is
+// cancelled after timeout. If f has not returned by then, t fails and f is
+// abandoned: it keeps running until it notices the cancelled context, but
+// everything it reports from then on is dropped, and the subtests it
+// starts are not run.
+// f must not call Parallel.
+func (t *H) RunWithTimeout(timeout time.Duration, f func(t *H)) bool {
+	ctx, cancel := context.WithTimeout(t.ctx, timeout)
+	defer cancel()
+	sub := &H{
+		ctx:       ctx,
+		cancel:    cancel,
+		name:      t.name,
 		suite:     t.suite,
 		parent:    t,
-		level:     t.level + 1,
+		level:     t.level,
 		reporters: t.reporters,
 	}
+	sub.w = standInWriter{sub, t.w}
+	sub.logger = log.New(standInWriter{sub, t.logger.Writer()}, t.logger.Prefix(), t.logger.Flags())
+
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			// FailNow and SkipNow exit through runtime.Goexit, whose
+			// recover returns nil.
+			if err := recover(); err != nil {
+				panicked <- fmt.Sprintf("%v\n\n%s", err, debug.Stack())
+			}
+			close(panicked)
+		}()
+		f(sub)
+	}()
+
+	select {
+	case err, ok := <-panicked:
+		if ok {
+			panic(err)
+		}
+		if sub.Skipped() && !sub.Failed() {
+			t.skip()
+		}
+		for _, m := range sub.recordedMetrics() {
+			t.RecordMetric(m.Name, m.Value, m.Unit)
+		}
+		return true
+	case <-ctx.Done():
+		atomic.StoreInt32(&sub.abandoned, 1)
+		t.Errorf("Test timed out after %v", timeout)
+		return false
+	}
+}
+
+// standInWriter writes to w, part of the output of the test the H of a
+// function run by RunWithTimeout stands in for, until the function is
+// abandoned.
+type standInWriter struct {
+	c *H
+	w io.Writer
+}
+
+func (w standInWriter) Write(b []byte) (int, error) {
+	if w.c.isAbandoned() {
+		return len(b), nil
+	}
+	p := w.c.parent
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return w.w.Write(b)
+}
+
+// run runs f as a subtest of t called name, returning the subtest once it
+// has completed or nil if name is filtered out. If isAttempt is set,
+// failures of the subtest are not propagated to t. If isNonFatal is set,
+// they are reported as non-fatal.
+func (t *H) run(name string, f func(t *H), isAttempt, isNonFatal bool) *H {
+	t.hasSub = true
+	testName, ok := t.suite.match.fullName(t, name)
+	if !ok || t.isAbandoned() {
+		return nil
+	}
+	t = &H{
+		barrier:    make(chan bool),
+		started:    make(chan bool),
+		signal:     make(chan bool),
+		name:       testName,
+		suite:      t.suite,
+		parent:     t,
+		level:      t.level + 1,
+		isAttempt:  isAttempt,
+		isNonFatal: isNonFatal,
+		reporters:  t.reporters,
+	}
 	t.w = indenter{t}
 	// Indent logs 8 spaces to distinguish them from sub-test headers.
 	const indent = "        "
-	t.logger = log.New(&t.output, indent, log.Lshortfile)
+	var output io.Writer = &t.output
+	if t.suite.hasEvents() {
+		output = io.MultiWriter(output, eventOutput{t})
+	}
+	t.logger = log.New(output, indent, log.Lshortfile)
 
 	if t.suite.opts.Verbose {
 		// Print directly to root's io.Writer so there is no delay.
@@ -471,6 +747,7 @@ func (t *H) Run(name string, f func(t *H)) bool {
 		}
 		fmt.Fprintf(root.w, "=== RUN   %s\n", t.name)
 	}
+	t.suite.emit(reporters.Event{Action: reporters.ActionRun, Test: t.name})
 	// Instead of reducing the running count of this test before calling the
 	// tRunner and increasing it afterwards, we rely on tRunner keeping the
 	// count correct. This ensures that a sequence of sequential tests runs
@@ -478,20 +755,25 @@ func (t *H) Run(name string, f func(t *H)) bool {
 	// may especially reduce surprises if *parallel == 1.
 	go tRunner(t, f)
 	<-t.signal
-	return !t.failed
+	return t
 }
 
 func (t *H) report() {
-	if t.parent == nil {
+	if t.parent == nil || t.isAbandoned() {
 		return
 	}
 	dstr := fmtDuration(t.duration)
 	format := "--- %s: %s (%s)\n"
 
 	status := t.status()
-	if status == testresult.Fail || t.suite.opts.Verbose {
+	if status == testresult.Fail || status == testresult.Flaky || status == testresult.NonFatal || t.suite.opts.Verbose {
 		t.flushToParent(format, status, t.name, dstr)
 	}
+	t.suite.emit(reporters.Event{
+		Action:  reporters.ResultAction(status),
+		Test:    t.name,
+		Elapsed: t.duration.Seconds(),
+	})
 
 	// TODO: store multiple buffers for subtests without indentation
 	// potentially add a TeeWriter which will output to both buffers
@@ -505,7 +787,7 @@ func (t *H) report() {
 	// could also write verbosely to the 'reporter sink'.  I'm fine with
 	// this being a TODO if you don't want to tackle it in this initial
 	// PR.
-	t.reporters.ReportTest(t.name, status, t.duration, t.output.Bytes())
+	t.reporters.ReportTest(t.name, status, t.duration, t.output.Bytes(), t.recordedMetrics())
 }
 
 // CleanOutputDir creates/empties an output directory and returns the cleaned path.
@@ -17,6 +17,7 @@ package harness
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -25,9 +26,13 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/coreos/mantle/harness/reporters"
+	"github.com/coreos/mantle/harness/testresult"
 )
 
 func TestMain(m *testing.M) {
@@ -71,6 +76,327 @@ func TestContextCancel(t *testing.T) {
 	}
 }
 
+func TestRunWithTimeout(t *testing.T) {
+	late := make(chan struct{})
+	reported := make(chan struct{})
+	veryLate := make(chan struct{})
+	finished := make(chan struct{})
+	suite := NewSuite(Options{Verbose: true}, Tests{
+		"Pass": func(h *H) {
+			ok := h.RunWithTimeout(time.Minute, func(h *H) {
+				h.Logf("in time")
+				h.RecordMetric("boot", 1, "s")
+			})
+			if !ok {
+				h.Errorf("RunWithTimeout = false")
+			}
+		},
+		"Timeout": func(h *H) {
+			ok := h.RunWithTimeout(10*time.Millisecond, func(h *H) {
+				defer close(finished)
+				h.Logf("started")
+				<-h.Context().Done()
+				if err := h.Context().Err(); err != context.DeadlineExceeded {
+					h.Fatalf("unexpected context error: %v", err)
+				}
+				// while the test tears down
+				<-late
+				h.Logf("late log")
+				h.RecordMetric("late", 1, "s")
+				h.Run("late-subtest", func(h *H) {
+					h.Logf("late subtest")
+				})
+				h.Errorf("late error")
+				close(reported)
+				// after the test has completed
+				<-veryLate
+				h.Fatalf("late failure")
+			})
+			if ok {
+				h.Errorf("RunWithTimeout = true")
+			}
+			close(late)
+			<-reported
+			h.Logf("torn down")
+		}})
+	buf := &bytes.Buffer{}
+	err := suite.runTests(buf, nil)
+	close(veryLate)
+	<-finished
+	if err != SuiteFailed {
+		t.Errorf("unexpected error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"in time", "--- PASS: Pass", "started", "Test timed out after 10ms", "torn down", "--- FAIL: Timeout"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "late") {
+		t.Errorf("output of the abandoned function was not dropped:\n%s", out)
+	}
+}
+
+func TestIsolatePanics(t *testing.T) {
+	var ran int32
+	suite := NewSuite(Options{IsolatePanics: true}, Tests{
+		"Panic": func(h *H) {
+			h.Parallel()
+			panic("boom")
+		},
+		"Pass": func(h *H) {
+			h.Parallel()
+			atomic.AddInt32(&ran, 1)
+		},
+	})
+	buf := &bytes.Buffer{}
+	if err := suite.runTests(buf, nil); err != SuiteFailed {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&ran) != 1 {
+		t.Error("other test did not run")
+	}
+	if out := buf.String(); !strings.Contains(out, "--- FAIL: Panic") || !strings.Contains(out, "panic: boom") {
+		t.Errorf("panic not reported in output:\n%s", out)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+	test := func(h *H) {
+		h.Parallel()
+		mu.Lock()
+		started = append(started, h.Name())
+		mu.Unlock()
+	}
+	suite := NewSuite(Options{
+		Parallel: 1,
+		Order:    []string{"c", "a"},
+	}, Tests{"a": test, "b": test, "c": test, "d": test})
+	if err := suite.runTests(&bytes.Buffer{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(started, ","), "c,a,b,d"; got != want {
+		t.Errorf("tests started in order %s, want %s", got, want)
+	}
+}
+
+func TestParallelism(t *testing.T) {
+	var running, max int32
+	test := func(h *H) {
+		h.Parallel()
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}
+	tests := make(Tests)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		tests[name] = test
+	}
+	suite := NewSuite(Options{Parallel: 3}, tests)
+	if err := suite.runTests(&bytes.Buffer{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if max != 3 {
+		t.Errorf("%d tests ran at once, want 3", max)
+	}
+}
+
+func TestRunNonFatal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "harness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reps := reporters.Reporters{
+		reporters.NewJSONReporter("report.json", "qemu", ""),
+		reporters.NewJUnitReporter("report.xml", "qemu", ""),
+	}
+	suite := NewSuite(Options{Reporters: reps}, Tests{
+		"Known": func(h *H) {
+			if h.RunNonFatal("nonfatal", func(h *H) {
+				h.Run("sub", func(h *H) {
+					h.Fail()
+				})
+			}) {
+				h.Errorf("RunNonFatal = true")
+			}
+		},
+	})
+	if err := suite.runTests(&bytes.Buffer{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := reps.Output(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := reporters.ReadJSONReport(filepath.Join(dir, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]testresult.TestResult)
+	for _, test := range report.Tests {
+		results[test.Name] = test.Result
+	}
+	want := map[string]testresult.TestResult{
+		"Known":              testresult.Pass,
+		"Known/nonfatal":     testresult.NonFatal,
+		"Known/nonfatal/sub": testresult.NonFatal,
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got results %v, want %v", results, want)
+	}
+
+	junit, err := ioutil.ReadFile(filepath.Join(dir, "report.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(junit, []byte(`<testsuites name="kola" tests="3" failures="0" skipped="2"`)) {
+		t.Errorf("non-fatal failures counted as failures:\n%s", junit)
+	}
+}
+
+func TestLock(t *testing.T) {
+	var mu, subMu sync.Mutex
+	mu.Lock()
+	subMu.Lock()
+	suite := NewSuite(Options{
+		Parallel: 1,
+		Order:    []string{"waiter", "sub-waiter", "other"},
+	}, Tests{
+		"waiter": func(h *H) {
+			h.Parallel()
+			// the only slot is free for the others while waiting
+			h.Lock(&mu)
+			mu.Unlock()
+		},
+		"sub-waiter": func(h *H) {
+			h.Parallel()
+			h.Run("sub", func(h *H) {
+				h.Lock(&subMu)
+				subMu.Unlock()
+			})
+		},
+		"other": func(h *H) {
+			h.Parallel()
+			mu.Unlock()
+			subMu.Unlock()
+		},
+	})
+	if err := suite.runTests(&bytes.Buffer{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type metricRecorder struct {
+	mu      sync.Mutex
+	metrics map[string][]reporters.Metric
+}
+
+func (r *metricRecorder) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte, metrics []reporters.Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = metrics
+}
+
+func (r *metricRecorder) Output(string) error             { return nil }
+func (r *metricRecorder) SetResult(testresult.TestResult) {}
+
+func TestRecordMetric(t *testing.T) {
+	rec := &metricRecorder{metrics: make(map[string][]reporters.Metric)}
+	suite := NewSuite(Options{Reporters: reporters.Reporters{rec}}, Tests{
+		"Metric": func(h *H) {
+			h.RecordMetric("boot", 1, "s")
+			h.RecordMetric("boot", 2, "s")
+		},
+		"Attempts": func(h *H) {
+			h.RunAttempts(2, func(h *H) {
+				h.RecordMetric("ssh", 3, "s")
+			})
+		},
+	})
+	if err := suite.runTests(&bytes.Buffer{}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, want := range map[string][]reporters.Metric{
+		"Metric":             {{Name: "boot", Value: 2, Unit: "s"}},
+		"Attempts":           {{Name: "ssh", Value: 3, Unit: "s"}},
+		"Attempts/attempt-1": {{Name: "ssh", Value: 3, Unit: "s"}},
+	} {
+		if got := rec.metrics[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got metrics %v, want %v", name, got, want)
+		}
+	}
+}
+
+type eventRecorder []reporters.Event
+
+func (r *eventRecorder) HandleEvent(e reporters.Event) {
+	*r = append(*r, e)
+}
+
+func TestEvents(t *testing.T) {
+	var events eventRecorder
+	suite := NewSuite(Options{
+		EventHandlers: []reporters.EventHandler{&events},
+	}, Tests{
+		"Events": func(h *H) {
+			h.Log("hello")
+			h.Run("sub", func(h *H) {
+				h.SkipNow()
+			})
+		}})
+	buf := &bytes.Buffer{}
+	if err := suite.runTests(buf, nil); err != nil {
+		t.Log("\n" + buf.String())
+		t.Error(err)
+	}
+
+	want := []string{
+		"run Events",
+		"output Events",
+		"run Events/sub",
+		"skip Events/sub",
+		"pass Events",
+		"pass ",
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s %s", e.Action, e.Test))
+		if e.Action == reporters.ActionOutput && !strings.Contains(e.Output, "hello") {
+			t.Errorf("unexpected output event: %q", e.Output)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %q, want %q", got, want)
+	}
+}
+
+func TestHasEvents(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		reps reporters.Reporters
+		want bool
+	}{
+		{"no reporters", nil, false},
+		{"no event handler", reporters.Reporters{reporters.NewJSONReporter("report.json", "", "")}, false},
+		{"event writer", reporters.Reporters{reporters.NewEventWriter(&bytes.Buffer{})}, true},
+	} {
+		suite := NewSuite(Options{Reporters: tt.reps}, Tests{})
+		if got := suite.hasEvents(); got != tt.want {
+			t.Errorf("%s: hasEvents() = %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
 func TestSubTests(t *testing.T) {
 	realTest := t
 	testCases := []struct {
@@ -268,6 +594,53 @@ func TestSubTests(t *testing.T) {
 		f: func(t *H) {
 			t.Skip()
 		},
+	}, {
+		desc: "attempt passing after failure is flaky",
+		output: `
+--- FLAKY: attempt passing after failure is flaky (N.NNs)
+    --- FAIL: attempt passing after failure is flaky/attempt-1 (N.NNs)
+            harness_test.go:NNN: first attempt
+        harness.go:NNN: attempt 1 of 3 failed, retrying`,
+		f: func(t *H) {
+			attempts := 0
+			if !t.RunAttempts(3, func(t *H) {
+				attempts++
+				if attempts == 1 {
+					t.Fatal("first attempt")
+				}
+			}) {
+				realTest.Error("RunAttempts reported failure")
+			}
+			if attempts != 2 {
+				realTest.Errorf("got %d attempts; want 2", attempts)
+			}
+		},
+	}, {
+		desc: "all attempts failing fails the test",
+		err:  SuiteFailed,
+		output: `
+--- FAIL: all attempts failing fails the test (N.NNs)
+    --- FAIL: all attempts failing fails the test/attempt-1 (N.NNs)
+        harness.go:NNN: attempt 1 of 2 failed, retrying
+    --- FAIL: all attempts failing fails the test/attempt-2 (N.NNs)`,
+		f: func(t *H) {
+			if t.RunAttempts(2, func(t *H) { t.FailNow() }) {
+				realTest.Error("RunAttempts reported success")
+			}
+		},
+	}, {
+		desc:   "non-fatal failure does not fail the test",
+		chatty: true,
+		output: `
+=== RUN   non-fatal failure does not fail the test
+=== RUN   non-fatal failure does not fail the test/nonfatal
+--- PASS: non-fatal failure does not fail the test (N.NNs)
+    --- NONFATAL: non-fatal failure does not fail the test/nonfatal (N.NNs)`,
+		f: func(t *H) {
+			if t.RunNonFatal("nonfatal", func(t *H) { t.FailNow() }) {
+				realTest.Error("RunNonFatal reported success")
+			}
+		},
 	}, {
 		desc:   "panic on goroutine fail after test exit",
 		err:    SuiteFailed,
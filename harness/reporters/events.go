@@ -0,0 +1,109 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+// Action describes what happened in an Event. The actions are those of
+// `go test -json` plus "flaky" for tests which passed on a retry and
+// "nonfatal" for tests whose failure is ignored.
+type Action string
+
+const (
+	ActionRun      Action = "run"      // the test has started running
+	ActionPause    Action = "pause"    // the test is waiting to run in parallel
+	ActionCont     Action = "cont"     // the test has continued running
+	ActionOutput   Action = "output"   // the test has logged output
+	ActionPass     Action = "pass"     // the test passed
+	ActionFail     Action = "fail"     // the test failed
+	ActionSkip     Action = "skip"     // the test was skipped
+	ActionFlaky    Action = "flaky"    // the test passed after failed attempts
+	ActionNonFatal Action = "nonfatal" // the test failed, but not fatally
+)
+
+// ResultAction returns the Action reporting a test finished with result.
+func ResultAction(result testresult.TestResult) Action {
+	switch result {
+	case testresult.Fail:
+		return ActionFail
+	case testresult.Skip:
+		return ActionSkip
+	case testresult.Flaky:
+		return ActionFlaky
+	case testresult.NonFatal:
+		return ActionNonFatal
+	default:
+		return ActionPass
+	}
+}
+
+// Event is a single step in the progress of a test run. Events with an
+// empty Test refer to the whole run.
+type Event struct {
+	Time    time.Time
+	Action  Action
+	Test    string  `json:",omitempty"`
+	Elapsed float64 `json:",omitempty"` // seconds
+	Output  string  `json:",omitempty"`
+}
+
+// EventHandler is implemented by anything following a test run while it
+// is in progress. Reporters implementing it receive the events of the
+// suite they report on. Events are delivered one at a time.
+type EventHandler interface {
+	HandleEvent(Event)
+}
+
+// eventWriter writes events as JSON lines in the format of `go test -json`.
+type eventWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewEventWriter returns a reporter which writes every event to w as soon
+// as it happens, one JSON object per line.
+func NewEventWriter(w io.Writer) *eventWriter {
+	return &eventWriter{enc: json.NewEncoder(w)}
+}
+
+func (r *eventWriter) HandleEvent(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(e)
+	}
+}
+
+// ReportTest is a no-op, the results were already written as events.
+func (r *eventWriter) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte, metrics []Metric) {
+}
+
+// Output returns the first error writing the events, if any.
+func (r *eventWriter) Output(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// SetResult is a no-op, the result was already written as an event.
+func (r *eventWriter) SetResult(result testresult.TestResult) {}
@@ -0,0 +1,43 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEventWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewEventWriter(&buf)
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	w.HandleEvent(Event{Time: start, Action: ActionRun, Test: "cl.basic"})
+	w.HandleEvent(Event{Time: start, Action: ActionOutput, Test: "cl.basic", Output: "booted \"m1\"\n"})
+	w.HandleEvent(Event{Time: start.Add(time.Second), Action: ActionFlaky, Test: "cl.basic", Elapsed: 1.5})
+	w.HandleEvent(Event{Time: start.Add(2 * time.Second), Action: ActionPass, Elapsed: 2})
+	if err := w.Output(""); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"Time":"2021-03-01T12:00:00Z","Action":"run","Test":"cl.basic"}
+{"Time":"2021-03-01T12:00:00Z","Action":"output","Test":"cl.basic","Output":"booted \"m1\"\n"}
+{"Time":"2021-03-01T12:00:01Z","Action":"flaky","Test":"cl.basic","Elapsed":1.5}
+{"Time":"2021-03-01T12:00:02Z","Action":"pass","Elapsed":2}
+`
+	if buf.String() != want {
+		t.Errorf("unexpected events, got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+// ArtifactCheck returns the problems found in an artifact, such as a
+// console log, of a machine created by the named test.
+type ArtifactCheck func(test string, contents []byte) []string
+
+// htmlArtifacts are the machine artifacts linked from the HTML report.
+var htmlArtifacts = []string{"console.txt", "journal.txt"}
+
+// htmlReporter writes a self-contained HTML page summarizing the tests.
+// The machine artifacts are looked up in the output directory of each
+// test, which must be the parent of the report directory, and linked
+// relative to it so the whole output directory can be published.
+type htmlReporter struct {
+	mu       sync.Mutex
+	tests    []htmlTest
+	result   testresult.TestResult
+	filename string
+	check    ArtifactCheck
+
+	// Context variables
+	platform string
+	version  string
+}
+
+type htmlTest struct {
+	Name     string
+	Short    string // name relative to the parent test
+	Depth    int
+	Result   testresult.TestResult
+	Duration time.Duration
+	Output   string
+	Metrics  []Metric
+	Machines []htmlMachine
+}
+
+type htmlMachine struct {
+	ID        string
+	Artifacts []htmlArtifact
+}
+
+type htmlArtifact struct {
+	Name     string
+	Link     string
+	Problems []string
+}
+
+// NewHTMLReporter returns a reporter writing an HTML summary to filename.
+// If check is not nil, the problems it finds in the machine artifacts are
+// listed next to them.
+func NewHTMLReporter(filename, platform, version string, check ArtifactCheck) *htmlReporter {
+	return &htmlReporter{
+		platform: platform,
+		version:  version,
+		filename: filename,
+		check:    check,
+	}
+}
+
+func (r *htmlReporter) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte, metrics []Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tests = append(r.tests, htmlTest{
+		Name:     name,
+		Result:   result,
+		Duration: duration,
+		Output:   string(b),
+		Metrics:  metrics,
+	})
+}
+
+func (r *htmlReporter) SetResult(result testresult.TestResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result = result
+}
+
+func (r *htmlReporter) Output(reportDir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tests := make([]htmlTest, len(r.tests))
+	copy(tests, r.tests)
+	// Sort subtests right after their parent.
+	treeKey := func(name string) string {
+		return strings.Replace(name, "/", "\x00", -1)
+	}
+	sort.SliceStable(tests, func(i, j int) bool {
+		return treeKey(tests[i].Name) < treeKey(tests[j].Name)
+	})
+
+	outputDir := filepath.Dir(reportDir)
+	counts := make(map[string]int)
+	for i := range tests {
+		t := &tests[i]
+		t.Depth = strings.Count(t.Name, "/")
+		t.Short = t.Name[strings.LastIndex(t.Name, "/")+1:]
+		if t.Depth == 0 {
+			counts[string(t.Result)]++
+		}
+		machines, err := r.machines(outputDir, t.Name)
+		if err != nil {
+			return err
+		}
+		t.Machines = machines
+	}
+
+	f, err := os.Create(filepath.Join(reportDir, r.filename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return htmlTemplate.Execute(f, struct {
+		Platform string
+		Version  string
+		Result   testresult.TestResult
+		Counts   map[string]int
+		Tests    []htmlTest
+	}{
+		Platform: r.platform,
+		Version:  r.version,
+		Result:   r.result,
+		Counts:   counts,
+		Tests:    tests,
+	})
+}
+
+// machines returns the machines created directly by the named test, they
+// are the directories in its output directory containing artifacts.
+func (r *htmlReporter) machines(outputDir, test string) ([]htmlMachine, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(outputDir, test))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var machines []htmlMachine
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		m := htmlMachine{ID: info.Name()}
+		for _, name := range htmlArtifacts {
+			file := filepath.Join(outputDir, test, m.ID, name)
+			contents, err := ioutil.ReadFile(file)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			a := htmlArtifact{
+				Name: name,
+				Link: (&url.URL{Path: path.Join("..", filepath.ToSlash(test), m.ID, name)}).String(),
+			}
+			if r.check != nil {
+				a.Problems = r.check(test, contents)
+			}
+			m.Artifacts = append(m.Artifacts, a)
+		}
+		if len(m.Artifacts) > 0 {
+			machines = append(machines, m)
+		}
+	}
+	return machines, nil
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": fmtSeconds,
+	"metric":  fmtMetric,
+	"indent":  func(depth int) int { return depth * 2 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>kola {{.Platform}} {{.Version}}: {{.Result}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.2em 0.6em; vertical-align: top; border-bottom: 1px solid #ddd; }
+td.duration { text-align: right; white-space: nowrap; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; }
+.PASS { color: #1a7f37; }
+.FAIL { color: #cf222e; font-weight: bold; }
+.SKIP { color: #6e7781; }
+.FLAKY { color: #bf8700; font-weight: bold; }
+.NONFATAL { color: #bf8700; }
+.problem { color: #cf222e; }
+.metric { color: #57606a; font-size: smaller; }
+</style>
+</head>
+<body>
+<h1>kola: <span class="{{.Result}}">{{.Result}}</span></h1>
+<p>
+{{- if .Platform}}Platform: {{.Platform}}<br>{{end}}
+{{- if .Version}}Version: {{.Version}}<br>{{end}}
+Tests: {{index .Counts "PASS"}} passed, {{index .Counts "FAIL"}} failed, {{index .Counts "FLAKY"}} flaky, {{index .Counts "NONFATAL"}} failed non-fatally, {{index .Counts "SKIP"}} skipped
+</p>
+<table>
+<tr><th>Test</th><th>Result</th><th>Duration</th><th>Machines</th></tr>
+{{- range .Tests}}
+<tr id="{{.Name}}">
+<td style="padding-left: {{indent .Depth}}em">
+{{- if .Output}}<details><summary>{{.Short}}</summary><pre>{{.Output}}</pre></details>{{else}}{{.Short}}{{end -}}
+{{- range .Metrics}}
+<div class="metric">{{.Name}}: {{metric .}}</div>
+{{- end}}
+</td>
+<td class="{{.Result}}">{{.Result}}</td>
+<td class="duration">{{seconds .Duration}}s</td>
+<td>
+{{- range .Machines}}
+<div>{{.ID}}:
+{{- range .Artifacts}} <a href="{{.Link}}">{{.Name}}</a>{{end}}
+{{- range .Artifacts}}{{$name := .Name}}{{range .Problems}}
+<div class="problem">{{.}} in {{$name}}</div>
+{{- end}}{{end}}
+</div>
+{{- end}}
+</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
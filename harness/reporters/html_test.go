@@ -0,0 +1,86 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+func TestHTMLReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the machine artifacts are in the output directory, the parent of
+	// the report directory
+	machineDir := filepath.Join(dir, "cl.fail", "m1")
+	if err := os.MkdirAll(machineDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(machineDir, "console.txt"), []byte("panic"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reportDir := filepath.Join(dir, "reports")
+	if err := os.Mkdir(reportDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(test string, contents []byte) []string {
+		return []string{"found <" + string(contents) + ">"}
+	}
+	r := NewHTMLReporter("report.html", "qemu", "1.0.0", check)
+	r.ReportTest("cl.fail", testresult.Fail, time.Second, []byte("<script>alert(\"x\")</script> & more\n"), nil)
+	r.ReportTest("cl.fail/sub", testresult.Pass, time.Second/2, nil, []Metric{{Name: "boot", Value: 1.5, Unit: "s"}})
+	r.ReportTest("cl.pass", testresult.Pass, 2*time.Second, nil, nil)
+	r.SetResult(testresult.Fail)
+	if err := r.Output(reportDir); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(reportDir, "report.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	for _, want := range []string{
+		"<title>kola qemu 1.0.0: FAIL</title>",
+		"Tests: 1 passed, 1 failed, 0 flaky, 0 failed non-fatally, 0 skipped",
+		"<pre>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; more\n</pre>",
+		`<a href="../cl.fail/m1/console.txt">console.txt</a>`,
+		`<div class="problem">found &lt;panic&gt; in console.txt</div>`,
+		`<div class="metric">boot: 1.5 s</div>`,
+		`<td class="FAIL">FAIL</td>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report does not contain %q:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("test output is not escaped:\n%s", html)
+	}
+	// subtests follow their parent
+	if i, j := strings.Index(html, `id="cl.fail/sub"`), strings.Index(html, `id="cl.pass"`); i < 0 || j < i {
+		t.Errorf("expected cl.fail/sub before cl.pass:\n%s", html)
+	}
+}
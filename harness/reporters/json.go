@@ -16,44 +16,77 @@ package reporters
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/coreos/mantle/harness/testresult"
 )
 
-type jsonReporter struct {
-	Tests    []jsonTest            `json:"tests"`
-	Result   testresult.TestResult `json:"result"`
-	filename string
+// JSONReport is the report written by a JSON reporter.
+type JSONReport struct {
+	Tests  []JSONTest            `json:"tests"`
+	Result testresult.TestResult `json:"result"`
 
 	// Context variables
 	Platform string `json:"platform"`
 	Version  string `json:"version"`
+
+	// RerunOf is the report of the run these tests were re-run from.
+	RerunOf string `json:"rerun_of,omitempty"`
 }
 
-type jsonTest struct {
+// JSONTest is the result of a test in a JSONReport.
+type JSONTest struct {
 	Name     string                `json:"name"`
 	Result   testresult.TestResult `json:"result"`
 	Duration time.Duration         `json:"duration"`
 	Output   string                `json:"output"`
+	Metrics  []Metric              `json:"metrics,omitempty"`
+}
+
+type jsonReporter struct {
+	mu sync.Mutex
+	JSONReport
+	filename string
 }
 
 func NewJSONReporter(filename, platform, version string) *jsonReporter {
 	return &jsonReporter{
-		Platform: platform,
-		Version:  version,
+		JSONReport: JSONReport{
+			Platform: platform,
+			Version:  version,
+		},
 		filename: filename,
 	}
 }
 
-func (r *jsonReporter) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte) {
-	r.Tests = append(r.Tests, jsonTest{
+// ReadJSONReport reads a report previously written by a JSON reporter.
+func ReadJSONReport(filename string) (*JSONReport, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &JSONReport{}
+	if err := json.NewDecoder(f).Decode(r); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filename, err)
+	}
+	return r, nil
+}
+
+func (r *jsonReporter) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte, metrics []Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Tests = append(r.Tests, JSONTest{
 		Name:     name,
 		Result:   result,
 		Duration: duration,
 		Output:   string(b),
+		Metrics:  metrics,
 	})
 }
 
@@ -64,9 +97,13 @@ func (r *jsonReporter) Output(path string) error {
 	}
 	defer f.Close()
 
-	return json.NewEncoder(f).Encode(r)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return json.NewEncoder(f).Encode(r.JSONReport)
 }
 
 func (r *jsonReporter) SetResult(result testresult.TestResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.Result = result
 }
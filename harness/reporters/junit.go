@@ -0,0 +1,257 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+// junitReporter writes test results in the JUnit XML format understood by
+// most CI systems. Every top-level test becomes a testsuite and the test
+// itself plus each of its subtests become testcases of that suite.
+type junitReporter struct {
+	mu       sync.Mutex
+	tests    []junitTest
+	filename string
+
+	// Context variables
+	platform string
+	version  string
+}
+
+type junitTest struct {
+	name     string
+	result   testresult.TestResult
+	duration time.Duration
+	output   string
+	metrics  []Metric
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Cases      []junitTestCase  `xml:"testcase"`
+
+	duration time.Duration
+}
+
+// junitProperties is a pointer in its parents since encoding/xml writes
+// empty parent elements of a>b fields.
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name         string           `xml:"name,attr"`
+	ClassName    string           `xml:"classname,attr"`
+	Time         string           `xml:"time,attr"`
+	Properties   *junitProperties `xml:"properties,omitempty"`
+	Failure      *junitMessage    `xml:"failure,omitempty"`
+	FlakyFailure *junitMessage    `xml:"flakyFailure,omitempty"`
+	Skipped      *junitMessage    `xml:"skipped,omitempty"`
+	SystemOut    string           `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func NewJUnitReporter(filename, platform, version string) *junitReporter {
+	return &junitReporter{
+		platform: platform,
+		version:  version,
+		filename: filename,
+	}
+}
+
+func (r *junitReporter) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte, metrics []Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tests = append(r.tests, junitTest{
+		name:     name,
+		result:   result,
+		duration: duration,
+		output:   string(b),
+		metrics:  metrics,
+	})
+}
+
+func (r *junitReporter) Output(path string) error {
+	f, err := os.Create(filepath.Join(path, r.filename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "\t")
+	if err := enc.Encode(r.suites()); err != nil {
+		return err
+	}
+	_, err = f.WriteString("\n")
+	return err
+}
+
+// SetResult is a no-op, JUnit derives the overall result from the testcases.
+func (r *junitReporter) SetResult(result testresult.TestResult) {}
+
+// suites groups the reported tests by their top-level test.
+func (r *junitReporter) suites() *junitTestSuites {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tests := make([]junitTest, len(r.tests))
+	copy(tests, r.tests)
+	sort.SliceStable(tests, func(i, j int) bool {
+		return tests[i].name < tests[j].name
+	})
+
+	var properties *junitProperties
+	if r.platform != "" || r.version != "" {
+		properties = &junitProperties{}
+	}
+	if r.platform != "" {
+		properties.Properties = append(properties.Properties, junitProperty{Name: "platform", Value: r.platform})
+	}
+	if r.version != "" {
+		properties.Properties = append(properties.Properties, junitProperty{Name: "version", Value: r.version})
+	}
+
+	// Failed attempts of tests which eventually passed are reported as
+	// flaky failures which CI systems do not count as failures.
+	flaky := make(map[string]bool)
+	for _, t := range tests {
+		if t.result == testresult.Flaky {
+			flaky[t.name] = true
+		}
+	}
+	hasFlakyParent := func(name string) bool {
+		for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name, "/") {
+			name = name[:i]
+			if flaky[name] {
+				return true
+			}
+		}
+		return false
+	}
+
+	all := &junitTestSuites{Name: "kola"}
+	var total time.Duration
+	index := make(map[string]int)
+	for _, t := range tests {
+		top := strings.SplitN(t.name, "/", 2)[0]
+		i, ok := index[top]
+		if !ok {
+			i = len(all.Suites)
+			index[top] = i
+			all.Suites = append(all.Suites, junitTestSuite{
+				Name:       top,
+				Properties: properties,
+			})
+		}
+		suite := &all.Suites[i]
+
+		tc := junitTestCase{
+			Name:      t.name,
+			ClassName: top,
+			Time:      fmtSeconds(t.duration),
+		}
+		if len(t.metrics) > 0 {
+			tc.Properties = &junitProperties{}
+		}
+		for _, m := range t.metrics {
+			tc.Properties.Properties = append(tc.Properties.Properties, junitProperty{Name: m.Name, Value: fmtMetric(m)})
+		}
+		switch {
+		case t.result == testresult.Fail && hasFlakyParent(t.name):
+			tc.FlakyFailure = &junitMessage{Message: "test failed, passed on retry", Text: t.output}
+		case t.result == testresult.NonFatal:
+			// known failures are not counted as failures
+			tc.Skipped = &junitMessage{Message: "test failed, the failure is not fatal", Text: t.output}
+			suite.Skipped++
+		case t.result == testresult.Fail:
+			tc.Failure = &junitMessage{Message: "test failed", Text: t.output}
+			suite.Failures++
+		case t.result == testresult.Skip:
+			tc.Skipped = &junitMessage{Message: "test skipped", Text: t.output}
+			suite.Skipped++
+		default:
+			tc.SystemOut = t.output
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+
+		// Only the top-level test accounts for the time spent in the
+		// suite, its subtests ran within it.
+		if t.name == top {
+			suite.duration = t.duration
+			total += t.duration
+		}
+	}
+
+	for i := range all.Suites {
+		suite := &all.Suites[i]
+		suite.Time = fmtSeconds(suite.duration)
+		all.Tests += suite.Tests
+		all.Failures += suite.Failures
+		all.Skipped += suite.Skipped
+	}
+	all.Time = fmtSeconds(total)
+
+	return all
+}
+
+// fmtMetric returns the value of m followed by its unit.
+func fmtMetric(m Metric) string {
+	return strings.TrimSpace(strconv.FormatFloat(m.Value, 'g', -1, 64) + " " + m.Unit)
+}
+
+// fmtSeconds returns a string representing d in seconds as used by JUnit.
+func fmtSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+func TestJUnitReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := NewJUnitReporter("report.xml", "qemu", "1.0.0")
+	r.ReportTest("cl.pass", testresult.Pass, 2*time.Second, []byte("booted\n"), []Metric{{Name: "boot", Value: 1.5, Unit: "s"}})
+	r.ReportTest("cl.fail", testresult.Fail, time.Second, []byte("expected <a> & got <b>\n"), nil)
+	r.ReportTest("cl.fail/sub", testresult.Fail, time.Second/2, []byte("sub failed\n"), nil)
+	r.ReportTest("cl.skip", testresult.Skip, 0, []byte("not supported\n"), nil)
+	r.ReportTest("cl.flaky", testresult.Flaky, 3*time.Second, nil, nil)
+	r.ReportTest("cl.flaky/attempt-1", testresult.Fail, time.Second, []byte("timed out\n"), nil)
+	if err := r.Output(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "report.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != junitGolden {
+		t.Errorf("unexpected report, got:\n%s\nwant:\n%s", got, junitGolden)
+	}
+}
+
+const junitGolden = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="kola" tests="6" failures="2" skipped="1" time="6.000">
+	<testsuite name="cl.fail" tests="2" failures="2" skipped="0" time="1.000">
+		<properties>
+			<property name="platform" value="qemu"></property>
+			<property name="version" value="1.0.0"></property>
+		</properties>
+		<testcase name="cl.fail" classname="cl.fail" time="1.000">
+			<failure message="test failed">expected &lt;a&gt; &amp; got &lt;b&gt;&#xA;</failure>
+		</testcase>
+		<testcase name="cl.fail/sub" classname="cl.fail" time="0.500">
+			<failure message="test failed">sub failed&#xA;</failure>
+		</testcase>
+	</testsuite>
+	<testsuite name="cl.flaky" tests="2" failures="0" skipped="0" time="3.000">
+		<properties>
+			<property name="platform" value="qemu"></property>
+			<property name="version" value="1.0.0"></property>
+		</properties>
+		<testcase name="cl.flaky" classname="cl.flaky" time="3.000"></testcase>
+		<testcase name="cl.flaky/attempt-1" classname="cl.flaky" time="1.000">
+			<flakyFailure message="test failed, passed on retry">timed out&#xA;</flakyFailure>
+		</testcase>
+	</testsuite>
+	<testsuite name="cl.pass" tests="1" failures="0" skipped="0" time="2.000">
+		<properties>
+			<property name="platform" value="qemu"></property>
+			<property name="version" value="1.0.0"></property>
+		</properties>
+		<testcase name="cl.pass" classname="cl.pass" time="2.000">
+			<properties>
+				<property name="boot" value="1.5 s"></property>
+			</properties>
+			<system-out>booted&#xA;</system-out>
+		</testcase>
+	</testsuite>
+	<testsuite name="cl.skip" tests="1" failures="0" skipped="1" time="0.000">
+		<properties>
+			<property name="platform" value="qemu"></property>
+			<property name="version" value="1.0.0"></property>
+		</properties>
+		<testcase name="cl.skip" classname="cl.skip" time="0.000">
+			<skipped message="test skipped">not supported&#xA;</skipped>
+		</testcase>
+	</testsuite>
+</testsuites>
+`
@@ -20,11 +20,18 @@ import (
 	"github.com/coreos/mantle/harness/testresult"
 )
 
+// Metric is a measurement recorded by a test, such as a boot time.
+type Metric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
 type Reporters []Reporter
 
-func (reps Reporters) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte) {
+func (reps Reporters) ReportTest(name string, result testresult.TestResult, duration time.Duration, b []byte, metrics []Metric) {
 	for _, r := range reps {
-		r.ReportTest(name, result, duration, b)
+		r.ReportTest(name, result, duration, b, metrics)
 	}
 }
 
@@ -45,7 +52,7 @@ func (reps Reporters) SetResult(s testresult.TestResult) {
 }
 
 type Reporter interface {
-	ReportTest(string, testresult.TestResult, time.Duration, []byte)
+	ReportTest(string, testresult.TestResult, time.Duration, []byte, []Metric)
 	Output(string) error
 	SetResult(testresult.TestResult)
 }
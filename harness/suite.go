@@ -74,7 +74,19 @@ type Options struct {
 	// Limit number of tests to run in parallel (0 means GOMAXPROCS).
 	Parallel int
 
+	// Start the listed tests first, in this order. The other tests
+	// start after them sorted by name.
+	Order []string
+
+	// Fail a test which panics instead of panicking the Suite,
+	// allowing the other tests and their cleanup to finish.
+	IsolatePanics bool
+
 	Reporters reporters.Reporters
+
+	// Receive events as the tests progress, in addition to the
+	// Reporters implementing reporters.EventHandler.
+	EventHandlers []reporters.EventHandler
 }
 
 // FlagSet can be used to setup options via command line flags.
@@ -106,6 +118,8 @@ func (o *Options) FlagSet(prefix string, errorHandling flag.ErrorHandling) *flag
 		"fail test binary execution after duration `d` (0 means unlimited)")
 	f.IntVar(&o.Parallel, prefix+"parallel", o.Parallel,
 		"run at most `n` tests in parallel")
+	f.BoolVar(&o.IsolatePanics, prefix+"isolate-panics", o.IsolatePanics,
+		"fail tests which panic instead of aborting the suite")
 	return f
 }
 
@@ -123,6 +137,13 @@ func (o *Options) init() {
 	if o.Parallel < 1 {
 		o.Parallel = runtime.GOMAXPROCS(0)
 	}
+	handlers := append([]reporters.EventHandler(nil), o.EventHandlers...)
+	for _, r := range o.Reporters {
+		if h, ok := r.(reporters.EventHandler); ok {
+			handlers = append(handlers, h)
+		}
+	}
+	o.EventHandlers = handlers
 }
 
 // Suite is a type passed to a TestMain function to run the actual tests.
@@ -145,6 +166,27 @@ type Suite struct {
 
 	// waiting is the number tests waiting to be run in parallel.
 	waiting int
+
+	// eventMu serializes the delivery of events to the handlers.
+	eventMu sync.Mutex
+}
+
+// hasEvents reports whether anything is listening to events.
+func (c *Suite) hasEvents() bool {
+	return len(c.opts.EventHandlers) > 0
+}
+
+// emit delivers e to all event handlers.
+func (c *Suite) emit(e reporters.Event) {
+	if !c.hasEvents() {
+		return
+	}
+	e.Time = time.Now()
+	c.eventMu.Lock()
+	defer c.eventMu.Unlock()
+	for _, h := range c.opts.EventHandlers {
+		h.HandleEvent(e)
+	}
 }
 
 func (c *Suite) waitParallel() {
@@ -275,17 +317,19 @@ func (s *Suite) Run() (err error) {
 
 func (s *Suite) runTests(out, tap io.Writer) error {
 	s.running = 1 // Set the count to 1 for the main (sequential) test.
+	start := time.Now()
 	t := &H{
 		signal:    make(chan bool),
 		barrier:   make(chan bool),
+		started:   make(chan bool),
 		w:         out,
 		tap:       tap,
 		suite:     s,
 		reporters: s.opts.Reporters,
 	}
 	tRunner(t, func(t *H) {
-		for name, test := range s.tests {
-			t.Run(name, test)
+		for _, name := range s.order() {
+			t.Run(name, s.tests[name])
 		}
 		// Run catching the signal rather than the tRunner as a separate
 		// goroutine to avoid adding a goroutine during the sequential
@@ -295,16 +339,37 @@ func (s *Suite) runTests(out, tap io.Writer) error {
 	if !t.ran {
 		return SuiteEmpty
 	}
+	elapsed := time.Since(start).Seconds()
 	if t.Failed() {
+		s.emit(reporters.Event{Action: reporters.ActionFail, Elapsed: elapsed})
 		s.opts.Reporters.SetResult(testresult.Fail)
 		return SuiteFailed
 	}
 
+	s.emit(reporters.Event{Action: reporters.ActionPass, Elapsed: elapsed})
 	s.opts.Reporters.SetResult(testresult.Pass)
 
 	return nil
 }
 
+// order returns the names of the tests in the order they are started.
+func (s *Suite) order() []string {
+	names := make([]string, 0, len(s.tests))
+	seen := make(map[string]bool)
+	for _, name := range s.opts.Order {
+		if _, ok := s.tests[name]; ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range s.tests.List() {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // outputPath returns the file name under Options.OutputDir.
 func (s *Suite) outputPath(path string) string {
 	return filepath.Join(s.opts.OutputDir, path)
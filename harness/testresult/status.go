@@ -18,6 +18,13 @@ const (
 	Fail TestResult = "FAIL"
 	Skip TestResult = "SKIP"
 	Pass TestResult = "PASS"
+
+	// Flaky is a test that passed after one or more failed attempts.
+	Flaky TestResult = "FLAKY"
+
+	// NonFatal is a failed test whose failure does not fail its parent,
+	// such as a test known to be broken.
+	NonFatal TestResult = "NONFATAL"
 )
 
 type TestResult string
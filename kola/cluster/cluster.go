@@ -32,6 +32,14 @@ type TestCluster struct {
 	platform.Cluster
 	NativeFuncs []string
 
+	// TestName is the name of the registered test, which may differ
+	// from the harness name, e.g. when the test is retried.
+	TestName string
+
+	// Params holds the values of the matrix parameters of the test,
+	// see register.Test.
+	Params map[string]string
+
 	// If set to true and a sub-test fails all future sub-tests will be skipped
 	FailFast   bool
 	hasFailure bool
@@ -43,11 +51,11 @@ func (t *TestCluster) Run(name string, f func(c TestCluster)) bool {
 		return t.H.Run(name, func(h *harness.H) {
 			func(c TestCluster) {
 				c.Skip("A previous test has already failed")
-			}(TestCluster{H: h, Cluster: t.Cluster})
+			}(TestCluster{H: h, Cluster: t.Cluster, TestName: t.TestName, Params: t.Params})
 		})
 	}
 	t.hasFailure = !t.H.Run(name, func(h *harness.H) {
-		f(TestCluster{H: h, Cluster: t.Cluster})
+		f(TestCluster{H: h, Cluster: t.Cluster, TestName: t.TestName, Params: t.Params})
 	})
 	return !t.hasFailure
 
@@ -55,7 +63,11 @@ func (t *TestCluster) Run(name string, f func(c TestCluster)) bool {
 
 // RunNative runs a registered NativeFunc on a remote machine
 func (t *TestCluster) RunNative(funcName string, m platform.Machine) bool {
-	command := fmt.Sprintf("./kolet run %q %q", t.H.Name(), funcName)
+	name := t.TestName
+	if name == "" {
+		name = t.H.Name()
+	}
+	command := fmt.Sprintf("./kolet run %q %q", name, funcName)
 	return t.Run(funcName, func(c TestCluster) {
 		client, err := m.SSHClient()
 		if err != nil {
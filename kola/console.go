@@ -0,0 +1,179 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/coreos/mantle/kola/register"
+)
+
+// Severities of console rules.
+const (
+	SeverityError   = "error"   // the test fails
+	SeverityWarning = "warning" // the match is only logged
+)
+
+// consoleRule finds a problem in the console or journal of a machine.
+type consoleRule struct {
+	name        string
+	desc        string
+	severity    string // SeverityError if empty
+	match       *regexp.Regexp
+	skipIfMatch *regexp.Regexp
+	skipFlag    *register.Flag
+}
+
+// consoleRuleFile is a rule in a file loaded by LoadConsoleRules.
+type consoleRuleFile struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Severity    string `yaml:"severity"`
+	Match       string `yaml:"match"`
+	SkipIfMatch string `yaml:"skip_if_match"`
+}
+
+// ConsoleProblem is a match of a console rule.
+type ConsoleProblem struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	Match       string `json:"match,omitempty"` // first subexpression
+}
+
+func (p ConsoleProblem) String() string {
+	if p.Match != "" {
+		return fmt.Sprintf("%s (%s)", p.Description, p.Match)
+	}
+	return p.Description
+}
+
+// LoadConsoleRules adds the console rules in a YAML or JSON file to the
+// built-in ones. The file holds a list of rules, each with a name, a
+// description, a match and optionally a skip_if_match regular expression
+// and a severity. A rule named like a built-in rule replaces it. The
+// severity is "error" unless set to "warning", matches of warnings do not
+// fail tests. A rule does not apply to output matching its skip_if_match.
+func LoadConsoleRules(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var rules []consoleRuleFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&rules); err != nil {
+		return fmt.Errorf("parsing %v: %v", file, err)
+	}
+
+	for _, r := range rules {
+		if r.Name == "" || r.Match == "" {
+			return fmt.Errorf("%v: every rule needs a name and a match", file)
+		}
+		rule := consoleRule{
+			name:     r.Name,
+			desc:     r.Description,
+			severity: r.Severity,
+		}
+		if rule.desc == "" {
+			rule.desc = r.Name
+		}
+		switch rule.severity {
+		case "":
+			rule.severity = SeverityError
+		case SeverityError, SeverityWarning:
+		default:
+			return fmt.Errorf("%v: rule %v has invalid severity %q", file, r.Name, r.Severity)
+		}
+		if rule.match, err = regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("%v: rule %v: %v", file, r.Name, err)
+		}
+		if r.SkipIfMatch != "" {
+			if rule.skipIfMatch, err = regexp.Compile(r.SkipIfMatch); err != nil {
+				return fmt.Errorf("%v: rule %v: %v", file, r.Name, err)
+			}
+		}
+
+		replaced := false
+		for i := range consoleChecks {
+			if consoleChecks[i].name == rule.name {
+				rule.skipFlag = consoleChecks[i].skipFlag
+				consoleChecks[i] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			consoleChecks = append(consoleChecks, rule)
+		}
+	}
+	return nil
+}
+
+// FindConsoleProblems checks some console output for badness and returns
+// the problems it finds. If t is specified, its flags and the console
+// rules it expects to trigger are respected.
+func FindConsoleProblems(output []byte, t *register.Test) []ConsoleProblem {
+	var ret []ConsoleProblem
+	for _, check := range consoleChecks {
+		if check.skipFlag != nil && t != nil && t.HasFlag(*check.skipFlag) {
+			continue
+		}
+		if t != nil && t.ExpectsConsoleRule(check.name) {
+			continue
+		}
+		match := check.match.FindSubmatch(output)
+		if match != nil {
+			if check.skipIfMatch != nil {
+				skipMatch := check.skipIfMatch.FindSubmatch(output)
+				if skipMatch != nil {
+					continue
+				}
+			}
+			p := ConsoleProblem{
+				Rule:        check.name,
+				Description: check.desc,
+				Severity:    check.severity,
+			}
+			if p.Severity == "" {
+				p.Severity = SeverityError
+			}
+			if len(match) > 1 {
+				// include first subexpression
+				p.Match = string(match[1])
+			}
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// CheckConsole checks some console output for badness and returns short
+// descriptions of any badness it finds which fails a test. If t is
+// specified, its flags and the console rules it expects to trigger are
+// respected.
+func CheckConsole(output []byte, t *register.Test) []string {
+	var ret []string
+	for _, p := range FindConsoleProblems(output, t) {
+		if p.Severity == SeverityError {
+			ret = append(ret, p.String())
+		}
+	}
+	return ret
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/mantle/kola/register"
+)
+
+func TestLoadConsoleRules(t *testing.T) {
+	saved := append([]consoleRule(nil), consoleChecks...)
+	defer func() { consoleChecks = saved }()
+
+	f, err := ioutil.TempFile("", "kola-console-rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	rules := `
+- name: oom-kill
+  description: OOM kill
+  severity: warning
+  match: 'Out of memory: Killed process \d+ \((.+)\)'
+- name: kernel-panic
+  description: custom panic
+  match: 'PANIC: (.+)'
+  skip_if_match: 'PANIC: expected'
+`
+	if _, err := f.WriteString(rules); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := LoadConsoleRules(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if len(consoleChecks) != len(saved)+1 {
+		t.Errorf("expected one new rule, got %d rules", len(consoleChecks))
+	}
+
+	output := []byte("Out of memory: Killed process 12 (foo)\nPANIC: bar\n")
+	expected := []ConsoleProblem{
+		{Rule: "kernel-panic", Description: "custom panic", Severity: SeverityError, Match: "bar"},
+		{Rule: "oom-kill", Description: "OOM kill", Severity: SeverityWarning, Match: "foo"},
+	}
+	if problems := FindConsoleProblems(output, nil); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected %+v, got %+v", expected, problems)
+	}
+	if errs := CheckConsole(output, nil); !reflect.DeepEqual(errs, []string{"custom panic (bar)"}) {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	test := &register.Test{ExpectedConsoleRules: []string{"kernel-panic"}}
+	if errs := CheckConsole(output, test); len(errs) != 0 {
+		t.Errorf("expected no errors for test expecting the rule, got %v", errs)
+	}
+	if errs := CheckConsole([]byte("PANIC: expected\n"), nil); len(errs) != 0 {
+		t.Errorf("expected skip_if_match to apply, got %v", errs)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"gopkg.in/yaml.v3"
+)
+
+// snoozeLayout is the format of the snooze_until date of denylist entries.
+const snoozeLayout = "2006-01-02"
+
+// DenylistEntry marks the tests matching Pattern as known to be broken.
+// An entry without platforms, arches or versions applies to all of them.
+type DenylistEntry struct {
+	Pattern     string   `yaml:"pattern"`
+	Platforms   []string `yaml:"platforms"`
+	Arches      []string `yaml:"arches"`
+	MinVersion  string   `yaml:"min_version"`
+	EndVersion  string   `yaml:"end_version"`
+	Tracking    string   `yaml:"tracking"`
+	SnoozeUntil string   `yaml:"snooze_until"`
+	NonFatal    bool     `yaml:"nonfatal"` // run the tests, ignoring failures
+
+	minVersion semver.Version
+	endVersion semver.Version
+	until      time.Time
+}
+
+func (e *DenylistEntry) String() string {
+	s := "denylisted by " + e.Pattern
+	if e.Tracking != "" {
+		s += ", see " + e.Tracking
+	}
+	if e.SnoozeUntil != "" {
+		s += ", until " + e.SnoozeUntil
+	}
+	return s
+}
+
+// hasVersionRange reports whether the entry only applies to some versions.
+func (e *DenylistEntry) hasVersionRange() bool {
+	return e.minVersion != (semver.Version{}) || e.endVersion != (semver.Version{})
+}
+
+// matches reports whether the entry applies to the named test on pltfrm.
+// A zero version matches every version range.
+func (e *DenylistEntry) matches(name, pltfrm string, version semver.Version) bool {
+	if match, _ := filepath.Match(e.Pattern, name); !match {
+		return false
+	}
+	if len(e.Platforms) > 0 && !hasString(e.Platforms, pltfrm) {
+		return false
+	}
+	if len(e.Arches) > 0 && !hasString(e.Arches, architecture(pltfrm)) {
+		return false
+	}
+	return !versionOutsideRange(version, e.minVersion, e.endVersion)
+}
+
+// ReadDenylist reads the denylist entries in a YAML or JSON file. Entries
+// whose snooze_until date has passed are dropped with a warning.
+func ReadDenylist(file string) ([]*DenylistEntry, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*DenylistEntry
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", file, err)
+	}
+
+	var ret []*DenylistEntry
+	for _, e := range entries {
+		if e.Pattern == "" {
+			return nil, fmt.Errorf("%v: every entry needs a pattern", file)
+		}
+		if _, err := filepath.Match(e.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("%v: entry %v: %v", file, e.Pattern, err)
+		}
+		if e.MinVersion != "" {
+			v, err := semver.NewVersion(e.MinVersion)
+			if err != nil {
+				return nil, fmt.Errorf("%v: entry %v: invalid min_version: %v", file, e.Pattern, err)
+			}
+			e.minVersion = *v
+		}
+		if e.EndVersion != "" {
+			v, err := semver.NewVersion(e.EndVersion)
+			if err != nil {
+				return nil, fmt.Errorf("%v: entry %v: invalid end_version: %v", file, e.Pattern, err)
+			}
+			e.endVersion = *v
+		}
+		if e.SnoozeUntil != "" {
+			if e.until, err = time.Parse(snoozeLayout, e.SnoozeUntil); err != nil {
+				return nil, fmt.Errorf("%v: entry %v: invalid snooze_until: %v", file, e.Pattern, err)
+			}
+			// the entry applies on the day itself
+			if !time.Now().Before(e.until.AddDate(0, 0, 1)) {
+				plog.Warningf("Denylist entry %v in %v expired on %v, its tests are run normally", e.Pattern, file, e.SnoozeUntil)
+				continue
+			}
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+// denylistEntry returns the first entry applying to the named test on
+// pltfrm, or nil if there is none.
+func denylistEntry(entries []*DenylistEntry, name, pltfrm string, version semver.Version) *DenylistEntry {
+	for _, e := range entries {
+		if e.matches(name, pltfrm, version) {
+			return e
+		}
+	}
+	return nil
+}
+
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+func TestReadDenylist(t *testing.T) {
+	f, err := ioutil.TempFile("", "kola-denylist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	denylist := `
+- pattern: cl.update.*
+  platforms: [aws]
+  min_version: 2905.0.0
+  end_version: 2983.0.0
+  tracking: https://example.com/issues/1
+- pattern: cl.basic
+  snooze_until: 2000-01-01
+- pattern: cl.*
+  nonfatal: true
+  snooze_until: 9999-12-31
+`
+	if _, err := f.WriteString(denylist); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	entries, err := ReadDenylist(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected the expired entry to be dropped, got %d entries", len(entries))
+	}
+
+	for _, tt := range []struct {
+		name, pltfrm, version string
+		pattern               string
+	}{
+		{"cl.update.payload", "aws", "2905.1.0", "cl.update.*"},
+		{"cl.update.payload", "aws", "2983.0.0", "cl.*"},
+		{"cl.update.payload", "gce", "2905.1.0", "cl.*"},
+		{"cl.basic", "aws", "2905.1.0", "cl.*"},
+		{"docker.base", "aws", "2905.1.0", ""},
+	} {
+		e := denylistEntry(entries, tt.name, tt.pltfrm, *semver.New(tt.version))
+		if tt.pattern == "" {
+			if e != nil {
+				t.Errorf("%s on %s %s: expected no entry, got %v", tt.name, tt.pltfrm, tt.version, e)
+			}
+		} else if e == nil || e.Pattern != tt.pattern {
+			t.Errorf("%s on %s %s: expected entry %s, got %v", tt.name, tt.pltfrm, tt.version, tt.pattern, e)
+		}
+	}
+}
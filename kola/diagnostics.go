@@ -0,0 +1,176 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/harness"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+)
+
+const (
+	// collectorTimeout bounds each collector command on the machine.
+	collectorTimeout = 30 * time.Second
+	// diagnosticsTimeout bounds collecting the diagnostics of a cluster.
+	diagnosticsTimeout = 3 * time.Minute
+)
+
+// defaultCollectors gather diagnostics from the machines of every failed test.
+var defaultCollectors = []register.Collector{
+	{
+		Name:    "failed-units",
+		Command: "systemctl --failed --all --no-pager",
+	},
+	{
+		Name:    "failed-units-status",
+		Command: "systemctl status --state=failed --full --no-pager",
+	},
+	{
+		Name:    "dmesg",
+		Command: "sudo dmesg",
+	},
+	{
+		Name:    "coredumps",
+		Command: "sudo coredumpctl list --no-pager && sudo coredumpctl info --no-pager",
+	},
+	{
+		Name:    "ignition",
+		Command: "sudo journalctl --no-pager -t ignition",
+	},
+	{
+		Name:    "mounts",
+		Command: "findmnt --list && df -h",
+	},
+	{
+		Name:    "network",
+		Command: "ip address show; ip route show; cat /etc/resolv.conf; networkctl status --all --no-pager",
+	},
+}
+
+// collectDiagnostics runs the collectors of t and the default collectors on
+// every machine of c, saving their output in the "diagnostics" directory of
+// each machine. The machines are handled in parallel. After
+// diagnosticsTimeout the collectors still running are cancelled and nothing
+// more is logged to h.
+func collectDiagnostics(h *harness.H, c platform.Cluster, t *register.Test) {
+	var collectors []register.Collector
+	collectors = append(collectors, defaultCollectors...)
+	collectors = append(collectors, t.Collectors...)
+
+	ctx, cancel := context.WithTimeout(h.Context(), diagnosticsTimeout)
+	defer cancel()
+
+	machines := c.Machines()
+	errs := make(chan error, len(machines))
+	for _, m := range machines {
+		go func(m platform.Machine) {
+			if err := collectMachineDiagnostics(ctx, m, h.OutputDir(), collectors); err != nil {
+				errs <- fmt.Errorf("machine %s: %v", m.ID(), err)
+				return
+			}
+			errs <- nil
+		}(m)
+	}
+
+	for range machines {
+		select {
+		case err := <-errs:
+			if err != nil {
+				h.Logf("Collecting diagnostics of %v", err)
+			}
+		case <-ctx.Done():
+			h.Logf("Collecting diagnostics timed out after %v", diagnosticsTimeout)
+			return
+		}
+	}
+}
+
+// collectMachineDiagnostics runs the collectors on m one after another
+// until ctx is done, saving their output below outputDir.
+func collectMachineDiagnostics(ctx context.Context, m platform.Machine, outputDir string, collectors []register.Collector) error {
+	dir := filepath.Join(outputDir, m.ID(), "diagnostics")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	for _, collector := range collectors {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cmd := fmt.Sprintf("timeout %d sh -c %s", int(collectorTimeout.Seconds()), shellQuote(collector.Command))
+		stdout, stderr, err := sshContext(ctx, m, cmd)
+		var out bytes.Buffer
+		out.Write(stdout)
+		if len(stderr) > 0 {
+			fmt.Fprintf(&out, "\n--- stderr ---\n%s\n", stderr)
+		}
+		if err != nil {
+			fmt.Fprintf(&out, "\n--- error ---\n%v\n", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, collector.Name+".txt"), out.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sshContext runs cmd on m like Machine.SSH but closes the connection once
+// ctx is done, so a hung machine cannot block the caller.
+func sshContext(ctx context.Context, m platform.Machine, cmd string) ([]byte, []byte, error) {
+	client, err := m.SSHClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer session.Close()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-finished:
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	err = session.Run(cmd)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return bytes.TrimSpace(stdout.Bytes()), bytes.TrimSpace(stderr.Bytes()), err
+}
+
+// shellQuote quotes s as a single argument for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
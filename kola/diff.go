@@ -0,0 +1,193 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/harness/reporters"
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+// minDurationChange is the smallest change in duration of a test which is
+// reported by DiffReports, shorter changes are noise.
+const minDurationChange = 30 * time.Second
+
+// TestChange describes how the result of a test differs between two runs.
+// The result is empty if the test is missing from a run.
+type TestChange struct {
+	Name        string
+	OldResult   testresult.TestResult
+	NewResult   testresult.TestResult
+	OldDuration time.Duration
+	NewDuration time.Duration
+}
+
+// MetricChange describes how a metric recorded by a test differs between
+// two runs.
+type MetricChange struct {
+	Test string
+	Name string
+	Unit string
+	Old  float64
+	New  float64
+}
+
+// ReportDiff is the difference between the JSON reports of two runs.
+type ReportDiff struct {
+	OldPlatform string
+	OldVersion  string
+	NewPlatform string
+	NewVersion  string
+
+	NewlyFailing    []TestChange
+	NewlyPassing    []TestChange
+	NewlySkipped    []TestChange
+	Missing         []TestChange
+	DurationChanged []TestChange
+	MetricChanged   []MetricChange
+}
+
+// Regressed reports whether tests fail which did not fail before.
+func (d *ReportDiff) Regressed() bool {
+	return len(d.NewlyFailing) > 0
+}
+
+// DiffReports compares the results of the tests in two JSON reports.
+// Tests which passed in both runs are reported if their duration changed
+// by more than threshold, a fraction of the old duration. Likewise the
+// metrics recorded in both runs are reported if they changed by more than
+// threshold.
+// The attempts of retried tests are not compared, only their outcome.
+func DiffReports(oldFile, newFile string, threshold float64) (*ReportDiff, error) {
+	oldReport, err := reporters.ReadJSONReport(oldFile)
+	if err != nil {
+		return nil, err
+	}
+	newReport, err := reporters.ReadJSONReport(newFile)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ReportDiff{
+		OldPlatform: oldReport.Platform,
+		OldVersion:  oldReport.Version,
+		NewPlatform: newReport.Platform,
+		NewVersion:  newReport.Version,
+	}
+
+	oldMetrics := make(map[[2]string]reporters.Metric)
+	for _, t := range oldReport.Tests {
+		if !isAttempt(t.Name) {
+			for _, m := range t.Metrics {
+				oldMetrics[[2]string{t.Name, m.Name}] = m
+			}
+		}
+	}
+	for _, t := range newReport.Tests {
+		if isAttempt(t.Name) {
+			continue
+		}
+		for _, m := range t.Metrics {
+			old, ok := oldMetrics[[2]string{t.Name, m.Name}]
+			if !ok || old.Value == 0 || old.Unit != m.Unit {
+				continue
+			}
+			if math.Abs(m.Value-old.Value) > threshold*math.Abs(old.Value) {
+				diff.MetricChanged = append(diff.MetricChanged, MetricChange{
+					Test: t.Name,
+					Name: m.Name,
+					Unit: m.Unit,
+					Old:  old.Value,
+					New:  m.Value,
+				})
+			}
+		}
+	}
+	sort.Slice(diff.MetricChanged, func(i, j int) bool {
+		a, b := diff.MetricChanged[i], diff.MetricChanged[j]
+		if a.Test != b.Test {
+			return a.Test < b.Test
+		}
+		return a.Name < b.Name
+	})
+
+	changes := make(map[string]*TestChange)
+	var names []string
+	change := func(name string) *TestChange {
+		c, ok := changes[name]
+		if !ok {
+			c = &TestChange{Name: name}
+			changes[name] = c
+			names = append(names, name)
+		}
+		return c
+	}
+	for _, t := range oldReport.Tests {
+		if !isAttempt(t.Name) {
+			c := change(t.Name)
+			c.OldResult, c.OldDuration = t.Result, t.Duration
+		}
+	}
+	for _, t := range newReport.Tests {
+		if !isAttempt(t.Name) {
+			c := change(t.Name)
+			c.NewResult, c.NewDuration = t.Result, t.Duration
+		}
+	}
+	sort.Strings(names)
+
+	passed := func(r testresult.TestResult) bool {
+		return r == testresult.Pass || r == testresult.Flaky
+	}
+	for _, name := range names {
+		c := *changes[name]
+		switch {
+		case c.NewResult == "":
+			diff.Missing = append(diff.Missing, c)
+		case c.NewResult == testresult.NonFatal:
+			// the failure is known and ignored
+		case c.NewResult == testresult.Fail && c.OldResult != testresult.Fail:
+			diff.NewlyFailing = append(diff.NewlyFailing, c)
+		case passed(c.NewResult) && c.OldResult == testresult.Fail:
+			diff.NewlyPassing = append(diff.NewlyPassing, c)
+		case c.NewResult == testresult.Skip && c.OldResult != "" && c.OldResult != testresult.Skip:
+			diff.NewlySkipped = append(diff.NewlySkipped, c)
+		case passed(c.NewResult) && passed(c.OldResult):
+			delta := c.NewDuration - c.OldDuration
+			if delta < 0 {
+				delta = -delta
+			}
+			if c.OldDuration > 0 && delta >= minDurationChange && float64(delta) > threshold*float64(c.OldDuration) {
+				diff.DurationChanged = append(diff.DurationChanged, c)
+			}
+		}
+	}
+	return diff, nil
+}
+
+// isAttempt reports whether name is an attempt of a retried test or one
+// of its subtests.
+func isAttempt(name string) bool {
+	for _, part := range strings.Split(name, "/")[1:] {
+		if strings.HasPrefix(part, "attempt-") {
+			return true
+		}
+	}
+	return false
+}
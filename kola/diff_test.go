@@ -0,0 +1,125 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/mantle/harness/reporters"
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+func TestDiffReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const minute = "60000000000"
+	oldFile := filepath.Join(dir, "old.json")
+	newFile := filepath.Join(dir, "new.json")
+	oldReport := `{"platform": "qemu", "version": "1.0.0", "tests": [
+		{"name": "cl.fails", "result": "PASS", "duration": ` + minute + `},
+		{"name": "cl.fixed", "result": "FAIL", "duration": ` + minute + `},
+		{"name": "cl.skipped", "result": "PASS", "duration": ` + minute + `},
+		{"name": "cl.removed", "result": "PASS", "duration": ` + minute + `},
+		{"name": "cl.slower", "result": "PASS", "duration": ` + minute + `},
+		{"name": "cl.same", "result": "PASS", "duration": ` + minute + `},
+		{"name": "cl.retried", "result": "PASS", "duration": ` + minute + `}
+	]}`
+	newReport := `{"platform": "qemu", "version": "1.1.0", "tests": [
+		{"name": "cl.fails", "result": "FAIL", "duration": ` + minute + `},
+		{"name": "cl.fixed", "result": "PASS", "duration": ` + minute + `},
+		{"name": "cl.skipped", "result": "SKIP", "duration": 0},
+		{"name": "cl.slower", "result": "PASS", "duration": 180000000000},
+		{"name": "cl.same", "result": "PASS", "duration": 70000000000},
+		{"name": "cl.retried", "result": "FLAKY", "duration": ` + minute + `},
+		{"name": "cl.retried/attempt-1", "result": "FAIL", "duration": ` + minute + `}
+	]}`
+	if err := ioutil.WriteFile(oldFile, []byte(oldReport), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(newFile, []byte(newReport), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := DiffReports(oldFile, newFile, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.OldVersion != "1.0.0" || diff.NewVersion != "1.1.0" || diff.NewPlatform != "qemu" {
+		t.Errorf("unexpected runs %+v", diff)
+	}
+	for _, tt := range []struct {
+		desc    string
+		changes []TestChange
+		want    string
+	}{
+		{"newly failing", diff.NewlyFailing, "cl.fails"},
+		{"newly passing", diff.NewlyPassing, "cl.fixed"},
+		{"newly skipped", diff.NewlySkipped, "cl.skipped"},
+		{"missing", diff.Missing, "cl.removed"},
+		{"duration changed", diff.DurationChanged, "cl.slower"},
+	} {
+		if len(tt.changes) != 1 || tt.changes[0].Name != tt.want {
+			t.Errorf("%s: expected %s, got %+v", tt.desc, tt.want, tt.changes)
+		}
+	}
+	if !diff.Regressed() {
+		t.Errorf("expected the new run to have regressed")
+	}
+}
+
+func TestDiffReportsNonFatal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeReport := func(name string, results map[string]testresult.TestResult) string {
+		r := reporters.NewJSONReporter(name, "qemu", "")
+		for test, result := range results {
+			r.ReportTest(test, result, time.Minute, nil, nil)
+		}
+		if err := r.Output(dir); err != nil {
+			t.Fatal(err)
+		}
+		return filepath.Join(dir, name)
+	}
+	oldFile := writeReport("old.json", map[string]testresult.TestResult{
+		"cl.known":     testresult.Pass,
+		"cl.regressed": testresult.Pass,
+	})
+	newFile := writeReport("new.json", map[string]testresult.TestResult{
+		"cl.known":                   testresult.Pass,
+		"cl.known/nonfatal":          testresult.NonFatal,
+		"cl.known/nonfatal/cl.basic": testresult.NonFatal,
+		"cl.regressed":               testresult.Fail,
+	})
+
+	diff, err := DiffReports(oldFile, newFile, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.NewlyFailing) != 1 || diff.NewlyFailing[0].Name != "cl.regressed" {
+		t.Errorf("unexpected newly failing tests %+v", diff.NewlyFailing)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+)
+
+// externalPrefix is the namespace of external tests which are not given a
+// name by their metadata.
+const externalPrefix = "ext."
+
+// externalMetadata is the metadata file of an external test, a YAML or
+// JSON file next to the script.
+type externalMetadata struct {
+	Name             string   `yaml:"name"`
+	Script           string   `yaml:"script"`
+	Config           string   `yaml:"config"`
+	ClusterSize      *int     `yaml:"cluster_size"`
+	Platforms        []string `yaml:"platforms"`
+	ExcludePlatforms []string `yaml:"exclude_platforms"`
+	Distros          []string `yaml:"distros"`
+	ExcludeDistros   []string `yaml:"exclude_distros"`
+	Channels         []string `yaml:"channels"`
+	ExcludeChannels  []string `yaml:"exclude_channels"`
+	Architectures    []string `yaml:"architectures"`
+	Tags             []string `yaml:"tags"`
+	RequiredFeatures []string `yaml:"required_features"`
+	MinMemory        int      `yaml:"min_memory"`       // MiB
+	MinCPUs          int      `yaml:"min_cpus"`         // virtual CPUs
+	AdditionalDisks  []string `yaml:"additional_disks"` // sizes such as 5G
+	AdditionalNics   int      `yaml:"additional_nics"`
+	ConsoleRules     []string `yaml:"expected_console_rules"`
+	Retries          int      `yaml:"retries"`
+	Timeout          string   `yaml:"timeout"`
+}
+
+// RegisterExternalTests registers the script tests in dir. Every test is
+// described by a metadata file, foo.yaml, foo.yml or foo.json, whose
+// script defaults to foo.sh or foo in the same directory. Unless the
+// metadata names the test it is called ext.foo.
+//
+// The script is copied to the home directory of every machine of the
+// test's cluster and run there as the core user, one machine after the
+// other. The private IPs of all machines are passed in KOLA_MACHINE_IPS,
+// separated by spaces. The test fails if the script exits non-zero on
+// any machine.
+func RegisterExternalTests(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		ext := filepath.Ext(info.Name())
+		if info.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		t, err := loadExternalTest(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return err
+		}
+		if _, ok := register.Tests[t.Name]; ok {
+			return fmt.Errorf("external test %v in %v: a test of this name is already registered", t.Name, dir)
+		}
+		register.Register(t)
+	}
+	return nil
+}
+
+// loadExternalTest returns the test described by the metadata file.
+func loadExternalTest(file string) (*register.Test, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var md externalMetadata
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&md); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", file, err)
+	}
+
+	dir := filepath.Dir(file)
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if md.Name == "" {
+		md.Name = externalPrefix + base
+	}
+
+	var script string
+	if md.Script != "" {
+		script = filepath.Join(dir, md.Script)
+	} else {
+		for _, name := range []string{base + ".sh", base} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				script = filepath.Join(dir, name)
+				break
+			}
+		}
+		if script == "" {
+			return nil, fmt.Errorf("%v: no script %v.sh or %v found", file, base, base)
+		}
+	}
+	if _, err := os.Stat(script); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+
+	t := &register.Test{
+		Name:             md.Name,
+		ClusterSize:      1,
+		Platforms:        md.Platforms,
+		ExcludePlatforms: md.ExcludePlatforms,
+		Distros:          md.Distros,
+		ExcludeDistros:   md.ExcludeDistros,
+		Channels:         md.Channels,
+		ExcludeChannels:  md.ExcludeChannels,
+		Architectures:    md.Architectures,
+		Tags:             md.Tags,
+		Retries:          md.Retries,
+		Run: func(c cluster.TestCluster) {
+			runExternalTest(c, script)
+		},
+	}
+	t.ExpectedConsoleRules = md.ConsoleRules
+	for _, f := range md.RequiredFeatures {
+		t.RequiredFeatures = append(t.RequiredFeatures, platform.Feature(f))
+	}
+	t.MachineOptions = platform.MachineOptions{
+		MinMemory:      md.MinMemory,
+		MinCPUs:        md.MinCPUs,
+		AdditionalNics: md.AdditionalNics,
+	}
+	for _, size := range md.AdditionalDisks {
+		t.MachineOptions.AdditionalDisks = append(t.MachineOptions.AdditionalDisks, platform.Disk{Size: size})
+	}
+	if md.ClusterSize != nil {
+		if *md.ClusterSize < 1 {
+			return nil, fmt.Errorf("%v: cluster_size must be at least 1", file)
+		}
+		t.ClusterSize = *md.ClusterSize
+	}
+	if md.Timeout != "" {
+		if t.Timeout, err = time.ParseDuration(md.Timeout); err != nil {
+			return nil, fmt.Errorf("%v: invalid timeout: %v", file, err)
+		}
+	}
+	if md.Config != "" {
+		config, err := ioutil.ReadFile(filepath.Join(dir, md.Config))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", file, err)
+		}
+		t.UserData = conf.Unknown(string(config))
+		t.UserDataV3 = t.UserData
+	}
+	return t, nil
+}
+
+// runExternalTest runs script on every machine of c.
+func runExternalTest(c cluster.TestCluster, script string) {
+	if err := c.DropFile(script); err != nil {
+		c.Fatalf("Copying %v to the machines: %v", script, err)
+	}
+
+	var ips []string
+	for _, m := range c.Machines() {
+		ips = append(ips, m.PrivateIP())
+	}
+	cmd := fmt.Sprintf("KOLA_MACHINE_IPS=%s ./%s", shellQuote(strings.Join(ips, " ")), shellQuote(filepath.Base(script)))
+	for _, m := range c.Machines() {
+		out, err := c.SSH(m, cmd)
+		if len(out) > 0 {
+			c.Logf("Output on machine %s:\n%s", m.ID(), out)
+		}
+		if err != nil {
+			c.Errorf("%s failed on machine %s: %v", filepath.Base(script), m.ID(), err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadExternalTest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"basic.sh":   "#!/bin/sh\ntrue\n",
+		"basic.yaml": "cluster_size: 2\ntags: [smoke]\ntimeout: 5m\nconfig: basic.ign\n",
+		"basic.ign":  `{"ignition": {"version": "2.2.0"}}`,
+		"named.json": `{"name": "cl.named", "script": "basic.sh", "platforms": ["qemu"]}`,
+		"typo.yaml":  "clustersize: 2\n",
+		"lonely.yml": "tags: [smoke]\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	test, err := loadExternalTest(filepath.Join(dir, "basic.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test.Name != "ext.basic" || test.ClusterSize != 2 || test.Timeout != 5*time.Minute || !reflect.DeepEqual(test.Tags, []string{"smoke"}) {
+		t.Errorf("unexpected test %+v", test)
+	}
+	if test.UserData == nil || !test.UserData.IsIgnitionCompatible() {
+		t.Errorf("expected an Ignition config")
+	}
+
+	test, err = loadExternalTest(filepath.Join(dir, "named.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test.Name != "cl.named" || test.ClusterSize != 1 || !reflect.DeepEqual(test.Platforms, []string{"qemu"}) {
+		t.Errorf("unexpected test %+v", test)
+	}
+
+	for _, name := range []string{"typo.yaml", "lonely.yml"} {
+		if _, err := loadExternalTest(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
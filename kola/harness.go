@@ -19,9 +19,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
@@ -69,9 +73,22 @@ var (
 	PacketOptions    = packetapi.Options{Options: &Options}    // glue to set platform options from main
 	QEMUOptions      = qemu.Options{Options: &Options}         // glue to set platform options from main
 
-	TestParallelism   int    //glue var to set test parallelism from main
-	TAPFile           string // if not "", write TAP results here
-	TorcxManifestFile string // torcx manifest to expose to tests, if set
+	TestParallelism    int      //glue var to set test parallelism from main
+	TestRetries        int      // glue var to set the minimum retries of each test from main
+	TAPFile            string   // if not "", write TAP results here
+	ReportFormats      []string // glue var to select the reports written to the reports dir
+	ShardIndex         int      // glue var to select the shard to run, counting from 1
+	ShardCount         int      // glue var to set the number of shards, 0 disables sharding
+	DurationsFiles     []string // reports of previous runs used to order tests and balance shards
+	PreviousReportFile string   // report of the last run, used to order tests if DurationsFiles is empty
+	RerunOf            string   // report of the run the tests are re-run from, if any
+	IsolatePanics      bool     // glue var to fail only the test which panics from main
+	EventsFile         string   // if not "", stream test events as JSON lines here, "-" is stdout
+	TagFilter          string   // glue var to select tests by an expression of their tags, see ParseTagExpr
+	DenylistFile       string   // if not "", skip the known broken tests listed here, see ReadDenylist
+	KeepFailed         bool     // glue var to leave the clusters of failed tests running from main
+	StateFile          string   // file recording the clusters left running, see UpdateState
+	TorcxManifestFile  string   // torcx manifest to expose to tests, if set
 	// TorcxManifest is the unmarshalled torcx manifest file. It is available for
 	// tests to access via `kola.TorcxManifest`. It will be nil if there was no
 	// manifest given to kola.
@@ -79,79 +96,91 @@ var (
 
 	UpdatePayloadFile string
 
-	consoleChecks = []struct {
-		desc        string
-		match       *regexp.Regexp
-		skipIfMatch *regexp.Regexp
-		skipFlag    *register.Flag
-	}{
+	// consoleChecks are the built-in console rules, extended by
+	// LoadConsoleRules.
+	consoleChecks = []consoleRule{
 		{
+			name:     "emergency-shell",
 			desc:     "emergency shell",
 			match:    regexp.MustCompile("Press Enter for emergency shell|Starting Emergency Shell|You are in emergency mode"),
 			skipFlag: &[]register.Flag{register.NoEmergencyShellCheck}[0],
 		},
 		{
+			name:  "kernel-panic",
 			desc:  "kernel panic",
 			match: regexp.MustCompile("Kernel panic - not syncing: (.*)"),
 		},
 		{
+			name:  "kernel-oops",
 			desc:  "kernel oops",
 			match: regexp.MustCompile("Oops:"),
 		},
 		{
+			name:  "kernel-warning",
 			desc:  "kernel warning",
 			match: regexp.MustCompile(`WARNING: CPU: \d+ PID: \d+ at (.+)`),
 		},
 		{
+			name:  "offline-device",
 			desc:  "failure of disk under I/O",
 			match: regexp.MustCompile("rejecting I/O to offline device"),
 		},
 		{
 			// Failure to set up Packet networking in initramfs,
 			// perhaps due to unresponsive metadata server
+			name:  "metadata-network",
 			desc:  "coreos-metadata failure to set up initramfs network",
 			match: regexp.MustCompile("Failed to start CoreOS Static Network Agent"),
 		},
 		{
 			// https://github.com/coreos/bugs/issues/2065
+			name:        "bonding-link-status",
 			desc:        "excessive bonding link status messages",
 			match:       regexp.MustCompile("(?s:link status up for interface [^,]+, enabling it in [0-9]+ ms.*?){3}"),
 			skipIfMatch: regexp.MustCompile("(bond.*? link status definitely up for interface)|(bond.*? first active interface up)|(bond.*? Gained carrier)|(bond.*? link becomes ready)"),
 		},
 		{
 			// https://github.com/coreos/bugs/issues/2180
+			name:  "ext4-delayed-allocation",
 			desc:  "ext4 delayed allocation failure",
 			match: regexp.MustCompile(`EXT4-fs \([^)]+\): Delayed block allocation failed for inode \d+ at logical offset \d+ with max blocks \d+ with (error \d+)`),
 		},
 		{
 			// https://github.com/coreos/bugs/issues/2284
+			name:  "grub-memory-corruption",
 			desc:  "GRUB memory corruption",
 			match: regexp.MustCompile("((alloc|free) magic) (is )?broken"),
 		},
 		{
 			// https://github.com/coreos/bugs/issues/2435
+			name:  "ignition-fetch-cancel",
 			desc:  "Ignition fetch cancellation race",
 			match: regexp.MustCompile("ignition\\[[0-9]+\\]: failed to fetch config: context canceled"),
 		},
 		{
 			// https://github.com/coreos/bugs/issues/2526
+			name:  "initrd-cleanup-terminated",
 			desc:  "initrd-cleanup.service terminated",
 			match: regexp.MustCompile("initrd-cleanup\\.service: Main process exited, code=killed, status=15/TERM"),
 		},
 		{
 			// kernel 4.14.11
+			name:  "bad-page-table",
 			desc:  "bad page table",
 			match: regexp.MustCompile("mm/pgtable-generic.c:\\d+: bad (p.d|pte)"),
 		},
 		{
+			name:  "go-panic",
 			desc:  "Go panic",
 			match: regexp.MustCompile("panic: (.*)"),
 		},
 		{
+			name:  "segfault",
 			desc:  "segfault",
 			match: regexp.MustCompile("SIGSEGV|=11/SEGV"),
 		},
 		{
+			name:  "core-dump",
 			desc:  "core dump",
 			match: regexp.MustCompile("[Cc]ore dump"),
 		},
@@ -192,6 +221,11 @@ func NewFlight(pltfrm string) (flight platform.Flight, err error) {
 func FilterTests(tests map[string]*register.Test, patterns []string, channel, offering string, pltfrm string, version semver.Version) (map[string]*register.Test, error) {
 	r := make(map[string]*register.Test)
 
+	tagExpr, err := ParseTagExpr(TagFilter)
+	if err != nil {
+		return nil, err
+	}
+
 	checkPlatforms := []string{pltfrm}
 
 	// qemu-unpriv has the same restrictions as QEMU but might also want additional restrictions due to the lack of a Local cluster
@@ -214,6 +248,9 @@ func FilterTests(tests map[string]*register.Test, patterns []string, channel, of
 		if noMatch {
 			continue
 		}
+		if !tagExpr.Match(t.Tags) {
+			continue
+		}
 		patternNotName := true
 		for _, pattern := range patterns {
 			if t.Name == pattern {
@@ -298,15 +335,20 @@ func versionOutsideRange(version, minVersion, endVersion semver.Version) bool {
 	return false
 }
 
-// RunTests is a harness for running multiple tests in parallel. Filters
-// tests based on glob patterns and by platform. Has access to all
-// tests either registered in this package or by imported packages that
-// register tests in their init() function.
-// outputDir is where various test logs and data will be written for
-// analysis after the test run. If it already exists it will be erased!
-func RunTests(patterns []string, channel, offering, pltfrm, outputDir string, sshKeys *[]agent.Key, remove bool) error {
-	var versionStr string
+// platformTests are the tests selected to run on one platform.
+type platformTests struct {
+	pltfrm    string
+	flight    platform.Flight
+	version   string
+	tests     map[string]*register.Test
+	durations map[string]time.Duration // of previous runs
+	denied    map[string]*DenylistEntry
+	shared    map[string]*sharedCluster
+}
 
+// selectTests filters the tests to run on pltfrm and creates the flight to
+// run them with. The flight is nil if no tests were selected.
+func selectTests(patterns []string, channel, offering, pltfrm, outputDir string, sshKeys *[]agent.Key, denylist []*DenylistEntry) (*platformTests, error) {
 	// Avoid incurring cost of starting machine in getClusterSemver when
 	// either:
 	// 1) none of the selected tests care about the version
@@ -318,6 +360,33 @@ func RunTests(patterns []string, channel, offering, pltfrm, outputDir string, ss
 		plog.Fatal(err)
 	}
 
+	durations, err := ReadDurations(DurationsFiles, pltfrm)
+	if err != nil {
+		return nil, fmt.Errorf("reading test durations: %v", err)
+	}
+
+	// Shard before the version is known so that every shard sees the
+	// same set of tests and no machine is started to check the semver
+	// for tests of other shards. Only the durations given explicitly are
+	// used: the last run of each shard differs, so shards balanced by
+	// them could skip or repeat tests.
+	if ShardCount > 1 {
+		tests = ShardTests(tests, ShardIndex, ShardCount, durations)
+		plog.Noticef("Running %d %s tests of shard %d/%d", len(tests), pltfrm, ShardIndex, ShardCount)
+	}
+
+	if len(DurationsFiles) == 0 && PreviousReportFile != "" {
+		durations, err = ReadDurations([]string{PreviousReportFile}, pltfrm)
+		if err != nil {
+			return nil, fmt.Errorf("reading test durations: %v", err)
+		}
+	}
+
+	pt := &platformTests{pltfrm: pltfrm, tests: tests, durations: durations}
+	if len(tests) == 0 {
+		return pt, nil
+	}
+
 	skipGetVersion := true
 	for name, t := range tests {
 		patternNotName := true
@@ -331,8 +400,61 @@ func RunTests(patterns []string, channel, offering, pltfrm, outputDir string, ss
 			skipGetVersion = false
 			break
 		}
+		// denylist entries for some versions need the version
+		if e := denylistEntry(denylist, name, pltfrm, semver.Version{}); e != nil && e.hasVersionRange() {
+			skipGetVersion = false
+			break
+		}
+	}
+
+	pt.flight, err = NewFlight(pltfrm)
+	if err != nil {
+		plog.Fatalf("creating flight for RunTests failed: %v", err)
+	}
+	(*pt.flight.GetBaseFlight()).AdditionalSshKeys = sshKeys
+
+	if !skipGetVersion {
+		plog.Infof("Creating %s cluster to check semver...", pltfrm)
+		version, err := getClusterSemver(pt.flight, outputDir)
+		if err != nil {
+			plog.Fatal(err)
+		}
+
+		pt.version = version.String()
+
+		// one more filter pass now that we know real version
+		pt.tests, err = FilterTests(tests, patterns, channel, offering, pltfrm, *version)
+		if err != nil {
+			plog.Fatal(err)
+		}
 	}
 
+	pt.shared = groupSharedClusters(pt.tests, outputDir)
+	pt.denied = make(map[string]*DenylistEntry)
+	version, _ := semver.NewVersion(pt.version)
+	if version == nil {
+		version = &semver.Version{}
+	}
+	for name := range pt.tests {
+		if e := denylistEntry(denylist, name, pltfrm, *version); e != nil {
+			pt.denied[name] = e
+		}
+	}
+	return pt, nil
+}
+
+// RunTests is a harness for running multiple tests in parallel. Filters
+// tests based on glob patterns and by platform. Has access to all
+// tests either registered in this package or by imported packages that
+// register tests in their init() function.
+// The tests are run on each of the given platforms. When there is more
+// than one, the tests of each platform are run as subtests of a test
+// named after the platform, e.g. "qemu/cl.basic", and the result of that
+// test is the result of the platform.
+// outputDir is where various test logs and data will be written for
+// analysis after the test run. If it already exists it will be erased!
+// ReportFormats must have been checked with CheckReportFormats.
+func RunTests(patterns []string, channel, offering string, pltfrms []string, outputDir string, sshKeys *[]agent.Key, remove bool) error {
 	if TorcxManifestFile != "" {
 		TorcxManifest = &torcx.Manifest{}
 		torcxManifestFile, err := os.Open(TorcxManifestFile)
@@ -345,50 +467,85 @@ func RunTests(patterns []string, channel, offering, pltfrm, outputDir string, ss
 		torcxManifestFile.Close()
 	}
 
-	flight, err := NewFlight(pltfrm)
-	if err != nil {
-		plog.Fatalf("creating flight for RunTests failed: %v", err)
-	}
-	(*flight.GetBaseFlight()).AdditionalSshKeys = sshKeys
-	if remove {
-		defer flight.Destroy()
+	var denylist []*DenylistEntry
+	if DenylistFile != "" {
+		var err error
+		denylist, err = ReadDenylist(DenylistFile)
+		if err != nil {
+			return err
+		}
 	}
 
-	if !skipGetVersion {
-		plog.Info("Creating cluster to check semver...")
-		version, err := getClusterSemver(flight, outputDir)
+	var selected []*platformTests
+	var versions []string
+	ntests := 0
+	for _, pltfrm := range pltfrms {
+		pt, err := selectTests(patterns, channel, offering, pltfrm, outputDir, sshKeys, denylist)
 		if err != nil {
-			plog.Fatal(err)
+			return err
 		}
+		if pt.flight != nil && remove {
+			defer pt.flight.Destroy()
+		}
+		if pt.version != "" && (len(versions) == 0 || versions[len(versions)-1] != pt.version) {
+			versions = append(versions, pt.version)
+		}
+		selected = append(selected, pt)
+		ntests += len(pt.tests)
+	}
+	if ntests == 0 && ShardCount > 1 {
+		fmt.Printf("PASS, no tests in shard %d/%d\n", ShardIndex, ShardCount)
+		return nil
+	}
 
-		versionStr = version.String()
-
-		// one more filter pass now that we know real version
-		tests, err = FilterTests(tests, patterns, channel, offering, pltfrm, *version)
-		if err != nil {
-			plog.Fatal(err)
+	pltfrm := strings.Join(pltfrms, ",")
+	versionStr := strings.Join(versions, ",")
+	reps := newReporters(pltfrm, versionStr)
+	if EventsFile != "" {
+		w := io.Writer(os.Stdout)
+		if EventsFile != "-" {
+			f, err := os.Create(EventsFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
 		}
+		reps = append(reps, reporters.NewEventWriter(w))
 	}
 
 	opts := harness.Options{
-		OutputDir: outputDir,
-		Parallel:  TestParallelism,
-		Verbose:   true,
-		Reporters: reporters.Reporters{
-			reporters.NewJSONReporter("report.json", pltfrm, versionStr),
-		},
+		OutputDir:     outputDir,
+		Parallel:      TestParallelism,
+		Verbose:       true,
+		Reporters:     reps,
+		IsolatePanics: IsolatePanics,
 	}
+	// Start the longest tests first so they do not stretch the run by
+	// starting late.
 	var htests harness.Tests
-	for _, test := range tests {
-		test := test // for the closure
-		run := func(h *harness.H) {
-			runTest(h, test, pltfrm, flight, remove)
+	for _, pt := range selected {
+		pt := pt // for the closure
+		if len(pltfrms) == 1 {
+			for _, test := range pt.tests {
+				htests.Add(test.Name, pt.runFunc(test, remove))
+			}
+			opts.Order = ScheduleTests(pt.tests, pt.durations)
+			continue
+		}
+		if len(pt.tests) == 0 {
+			continue
 		}
-		htests.Add(test.Name, run)
+		htests.Add(pt.pltfrm, func(h *harness.H) {
+			h.Parallel()
+			for _, name := range ScheduleTests(pt.tests, pt.durations) {
+				h.Run(name, pt.runFunc(pt.tests[name], remove))
+			}
+		})
 	}
 
 	suite := harness.NewSuite(opts, htests)
-	err = suite.Run()
+	err := suite.Run()
 
 	if TAPFile != "" {
 		src := filepath.Join(outputDir, "test.tap")
@@ -406,6 +563,126 @@ func RunTests(patterns []string, channel, offering, pltfrm, outputDir string, ss
 	return err
 }
 
+// runFunc returns the harness test function running test on the platform.
+func (pt *platformTests) runFunc(test *register.Test, remove bool) func(*harness.H) {
+	return func(h *harness.H) {
+		shared := pt.shared[test.Name]
+		if shared != nil {
+			defer shared.done(h, test, remove)
+		}
+		if missing := test.MissingFeatures(pt.flight.Features()); len(missing) > 0 {
+			h.Skipf("platform %v does not support %v", pt.pltfrm, missing)
+		}
+		denied := pt.denied[test.Name]
+		if denied != nil && !denied.NonFatal {
+			h.Skipf("Skipping, %v", denied)
+		}
+		h.Parallel()
+		retries := test.Retries
+		if TestRetries > retries {
+			retries = TestRetries
+		}
+		run := func(h *harness.H) {
+			if shared != nil {
+				// the console is checked by h once the tests
+				// after it are done with the cluster
+				defer shared.done(h, test, remove)
+				// wait for the tests before on the cluster
+				// without holding a parallel slot
+				h.Lock(&shared.mu)
+				defer shared.mu.Unlock()
+			}
+			if retries == 0 {
+				runTest(h, test, pt.pltfrm, pt.flight, remove, KeepFailed, shared)
+				return
+			}
+			// only the cluster of the last attempt is kept, the
+			// test passes if an earlier one does
+			attempt := 0
+			h.RunAttempts(retries+1, func(h *harness.H) {
+				attempt++
+				keep := KeepFailed && attempt == retries+1
+				runTest(h, test, pt.pltfrm, pt.flight, remove, keep, shared)
+			})
+		}
+		if denied != nil {
+			if !h.RunNonFatal("nonfatal", run) {
+				h.Logf("Ignoring the failure, %v", denied)
+			}
+			return
+		}
+		run(h)
+	}
+}
+
+// reportFormats are the known ReportFormats.
+var reportFormats = []string{"json", "junit", "html"}
+
+// CheckReportFormats returns an error if formats has an unknown or
+// repeated report format, so that bad flags are caught before any machine
+// is created.
+func CheckReportFormats(formats []string) error {
+	seen := make(map[string]bool)
+	for _, format := range formats {
+		known := false
+		for _, f := range reportFormats {
+			if f == format {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("invalid report format %q, expected one of %v", format, strings.Join(reportFormats, ", "))
+		}
+		if seen[format] {
+			return fmt.Errorf("report format %q given more than once", format)
+		}
+		seen[format] = true
+	}
+	return nil
+}
+
+// newReporters returns a reporter for each of the ReportFormats, which the
+// caller has checked with CheckReportFormats.
+func newReporters(pltfrm, version string) reporters.Reporters {
+	var reps reporters.Reporters
+	for _, format := range ReportFormats {
+		switch format {
+		case "json":
+			r := reporters.NewJSONReporter("report.json", pltfrm, version)
+			r.RerunOf = RerunOf
+			reps = append(reps, r)
+		case "junit":
+			reps = append(reps, reporters.NewJUnitReporter("report.xml", pltfrm, version))
+		case "html":
+			reps = append(reps, reporters.NewHTMLReporter("report.html", pltfrm, version, checkArtifact))
+		}
+	}
+	return reps
+}
+
+// checkArtifact runs the console rules on an artifact of a machine created by
+// the named test or one of its subtests.
+func checkArtifact(name string, contents []byte) []string {
+	// the test is the first part of the name which is a registered
+	// test, it is preceded by the platform when running on several
+	var t *register.Test
+	for _, part := range strings.Split(name, "/") {
+		if t = register.Tests[part]; t != nil {
+			break
+		}
+	}
+	var problems []string
+	for _, p := range FindConsoleProblems(contents, t) {
+		if p.Severity == SeverityWarning {
+			problems = append(problems, "warning: "+p.String())
+		} else {
+			problems = append(problems, p.String())
+		}
+	}
+	return problems
+}
+
 // getClusterSemVer returns the CoreOS semantic version via starting a
 // machine and checking
 func getClusterSemver(flight platform.Flight, outputDir string) (*semver.Version, error) {
@@ -458,36 +735,67 @@ func parseCLVersion(input string) (*semver.Version, error) {
 // runTest is a harness for running a single test.
 // outputDir is where various test logs and data will be written for
 // analysis after the test run. It should already exist.
-func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flight, remove bool) {
-	h.Parallel()
-
+// The cluster is left running if the test fails and keepFailed is set.
+func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flight, remove, keepFailed bool, shared *sharedCluster) {
 	rconf := &platform.RuntimeConfig{
 		OutputDir:          h.OutputDir(),
 		NoSSHKeyInUserData: t.HasFlag(register.NoSSHKeyInUserData),
 		NoSSHKeyInMetadata: t.HasFlag(register.NoSSHKeyInMetadata),
 		NoEnableSelinux:    t.HasFlag(register.NoEnableSelinux),
 	}
-	c, err := flight.NewCluster(rconf)
-	if err != nil {
-		h.Fatalf("Cluster failed: %v", err)
+	if shared != nil {
+		rconf.OutputDir = shared.outputDir
+		if err := os.MkdirAll(rconf.OutputDir, 0777); err != nil {
+			h.Fatalf("Cluster failed: %v", err)
+		}
+	}
+	var c platform.Cluster
+	reused := shared != nil && shared.cluster != nil
+	if reused {
+		c = shared.cluster
+		shared.cluster = nil
+		h.Logf("Reusing cluster %v of a previous test", c.Name())
+	} else {
+		var err error
+		c, err = flight.NewCluster(rconf)
+		if err != nil {
+			h.Fatalf("Cluster failed: %v", err)
+		}
 	}
+	if shared != nil {
+		shared.start(h, t, c, reused)
+	}
+	started := reused
 	defer func() {
-		if remove {
+		if shared != nil {
+			shared.checkJournal(h, t, c)
+		}
+		if h.Failed() {
+			collectDiagnostics(h, c, t)
+		}
+		if shared != nil && started && !h.Failed() {
+			// leave the cluster to the next test
+			shared.cluster = c
+			return
+		}
+		if keepFailed && h.Failed() {
+			keepCluster(h, c, flight)
+		} else if remove {
 			c.Destroy()
 		}
+		if shared != nil {
+			shared.release(c, remove && !(keepFailed && h.Failed()))
+			return
+		}
 		for id, output := range c.ConsoleOutput() {
-			for _, badness := range CheckConsole([]byte(output), t) {
-				h.Errorf("Found %s on machine %s console", badness, id)
-			}
+			reportConsoleProblems(h, FindConsoleProblems([]byte(output), t), "machine "+id+" console")
 		}
 		for id, output := range c.JournalOutput() {
-			for _, badness := range CheckConsole([]byte(output), t) {
-				h.Errorf("Found %s on machine %s journal", badness, id)
-			}
+			reportConsoleProblems(h, FindConsoleProblems([]byte(output), t), "machine "+id+" journal")
 		}
 	}()
 
-	if t.ClusterSize > 0 {
+	if t.ClusterSize > 0 && !reused {
 		var userdata *conf.UserData
 		if Options.IgnitionVersion == "v2" {
 			userdata = t.UserData
@@ -506,9 +814,10 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 			userdata = userdata.Subst("$discovery", url)
 		}
 
-		if _, err := platform.NewMachines(c, userdata, t.ClusterSize); err != nil {
+		if _, err := platform.NewMachinesWithOptions(c, userdata, t.ClusterSize, t.MachineOptions); err != nil {
 			h.Fatalf("Cluster failed starting machines: %v", err)
 		}
+		started = true
 	}
 
 	// pass along all registered native functions
@@ -521,6 +830,8 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 	tcluster := cluster.TestCluster{
 		H:           h,
 		Cluster:     c,
+		TestName:    t.Name,
+		Params:      t.Params,
 		NativeFuncs: names,
 		FailFast:    t.FailFast,
 	}
@@ -537,7 +848,94 @@ func runTest(h *harness.H, t *register.Test, pltfrm string, flight platform.Flig
 	}()
 
 	// run test
-	t.Run(tcluster)
+	if t.Timeout > 0 {
+		runWithTimeout(h, t, tcluster)
+	} else {
+		t.Run(tcluster)
+	}
+}
+
+// reportConsoleProblems fails h for the problems found in source which are
+// errors and logs the warnings.
+func reportConsoleProblems(h *harness.H, problems []ConsoleProblem, source string) {
+	for _, p := range problems {
+		if p.Severity == SeverityWarning {
+			h.Logf("Found %s on %s (warning)", p, source)
+		} else {
+			h.Errorf("Found %s on %s", p, source)
+		}
+	}
+}
+
+// keepCluster leaves c running for debugging. It is recorded in the state
+// file for `kola destroy` and taken out of the flight so destroying the
+// flight does not destroy it.
+func keepCluster(h *harness.H, c platform.Cluster, flight platform.Flight) {
+	cs, err := NewClusterState(c, h.Name(), h.OutputDir())
+	if err != nil {
+		h.Errorf("Recording kept cluster %v: %v", c.Name(), err)
+		c.Destroy()
+		return
+	}
+
+	err = UpdateState(StateFile, func(s *State) error {
+		s.Clusters = append(s.Clusters, cs)
+		return nil
+	})
+	if err != nil {
+		h.Errorf("Recording kept cluster %v in %v: %v", cs.Name, StateFile, err)
+		c.Destroy()
+		return
+	}
+	flight.GetBaseFlight().DelCluster(c)
+
+	h.Logf("Keeping cluster %v, remove it with: kola destroy %v", cs.Name, cs.Name)
+	for _, m := range cs.Machines {
+		h.Logf("Machine %v: %v", m.ID, sshCommand(m.IP))
+	}
+	if keys := flight.GetBaseFlight().AdditionalSshKeys; keys == nil || len(*keys) == 0 {
+		h.Logf("kola's own SSH key is gone once kola exits, run with -k to log in with your keys")
+	}
+}
+
+// sshCommand returns the command to log in to a machine at addr, which may
+// include a port.
+func sshCommand(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Sprintf("ssh core@%s", addr)
+	}
+	return fmt.Sprintf("ssh -p %s core@%s", port, host)
+}
+
+// runWithTimeout runs t on tcluster until it returns or t.Timeout has
+// passed. In the latter case the test fails, a dump of all goroutines is
+// written to its output directory and the test function is abandoned so the
+// caller can tear down the cluster; whatever it reports from then on is
+// dropped.
+func runWithTimeout(h *harness.H, t *register.Test, tcluster cluster.TestCluster) {
+	if h.RunWithTimeout(t.Timeout, func(h *harness.H) {
+		tcluster.H = h
+		t.Run(tcluster)
+	}) {
+		return
+	}
+	dump := filepath.Join(h.OutputDir(), "goroutines.txt")
+	if err := ioutil.WriteFile(dump, goroutineDump(), 0644); err != nil {
+		h.Errorf("Writing goroutine dump: %v", err)
+	}
+}
+
+// goroutineDump returns the stack traces of all goroutines.
+func goroutineDump() []byte {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
 }
 
 // architecture returns the machine architecture of the given platform.
@@ -585,37 +983,23 @@ func scpKolet(c cluster.TestCluster, mArch string) {
 	c.Fatalf("Unable to locate kolet binary for %s", mArch)
 }
 
-// CheckConsole checks some console output for badness and returns short
-// descriptions of any badness it finds. If t is specified, its flags are
-// respected.
-func CheckConsole(output []byte, t *register.Test) []string {
-	var ret []string
-	for _, check := range consoleChecks {
-		if check.skipFlag != nil && t != nil && t.HasFlag(*check.skipFlag) {
-			continue
-		}
-		match := check.match.FindSubmatch(output)
-		if match != nil {
-			if check.skipIfMatch != nil {
-				skipMatch := check.skipIfMatch.FindSubmatch(output)
-				if skipMatch != nil {
-					continue
-				}
-			}
-			badness := check.desc
-			if len(match) > 1 {
-				// include first subexpression
-				badness += fmt.Sprintf(" (%s)", match[1])
-			}
-			ret = append(ret, badness)
-		}
+// defaultBaseDirName holds the output directories of runs without an
+// explicit output directory.
+const defaultBaseDirName = "_kola_temp"
+
+// PreviousReport returns the JSON report of the last run on platform in the
+// default output directory, or "" if there is none. It must be called
+// before SetupOutputDir moves the link to the last run.
+func PreviousReport(platform string) string {
+	report, err := filepath.EvalSymlinks(filepath.Join(defaultBaseDirName, platform+"-latest", "reports", "report.json"))
+	if err != nil {
+		return ""
 	}
-	return ret
+	return report
 }
 
 func SetupOutputDir(outputDir, platform string) (string, error) {
 	defaulted := outputDir == ""
-	defaultBaseDirName := "_kola_temp"
 	defaultDirName := fmt.Sprintf("%s-%s-%d", platform, time.Now().Format("2006-01-02-1504"), os.Getpid())
 
 	if defaulted {
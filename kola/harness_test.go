@@ -0,0 +1,49 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"testing"
+)
+
+func TestReportFormats(t *testing.T) {
+	defer func(formats []string) { ReportFormats = formats }(ReportFormats)
+
+	for _, tt := range []struct {
+		formats []string
+		ok      bool
+	}{
+		{nil, true},
+		{[]string{"json"}, true},
+		{[]string{"json", "junit", "html"}, true},
+		{[]string{"xml"}, false},
+		{[]string{"json", "JSON"}, false},
+		{[]string{""}, false},
+		{[]string{"json", "html", "json"}, false},
+	} {
+		err := CheckReportFormats(tt.formats)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckReportFormats(%q) = %v", tt.formats, err)
+		}
+
+		if !tt.ok {
+			continue
+		}
+		ReportFormats = tt.formats
+		if reps := newReporters("qemu", ""); len(reps) != len(tt.formats) {
+			t.Errorf("newReporters with %q returned %d reporters", tt.formats, len(reps))
+		}
+	}
+}
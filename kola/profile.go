@@ -0,0 +1,70 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is a kola.yaml file of named profiles, each setting kola
+// flags by their name.
+type configFile struct {
+	Profiles map[string]map[string]yaml.Node `yaml:"profiles"`
+}
+
+// ReadProfile returns the values the named profile of a YAML or JSON config
+// file gives to kola flags, by flag name. A flag taking a list may be
+// given a YAML list, whose items are passed to it in turn like a repeated
+// flag.
+func ReadProfile(file, name string) (map[string][]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config configFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", file, err)
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%v: no profile %q", file, name)
+	}
+	ret := make(map[string][]string)
+	for flag, node := range profile {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			ret[flag] = []string{node.Value}
+		case yaml.SequenceNode:
+			values := []string{}
+			for _, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("%v: profile %v: %v must be a value or a list of values", file, name, flag)
+				}
+				values = append(values, item.Value)
+			}
+			ret[flag] = values
+		default:
+			return nil, fmt.Errorf("%v: profile %v: %v must be a value or a list of values", file, name, flag)
+		}
+	}
+	return ret, nil
+}
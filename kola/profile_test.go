@@ -0,0 +1,69 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestReadProfile(t *testing.T) {
+	f, err := ioutil.TempFile("", "kola-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	config := `
+profiles:
+  aws-arm64-nightly:
+    platform: aws
+    board: arm64-usr
+    aws-type: a1.large
+    parallel: 4
+    azure-version: 2905.10
+    debug-systemd-unit: [a.service, b.service]
+  broken:
+    platform: {name: aws}
+`
+	if _, err := f.WriteString(config); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	profile, err := ReadProfile(f.Name(), "aws-arm64-nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"platform":           {"aws"},
+		"board":              {"arm64-usr"},
+		"aws-type":           {"a1.large"},
+		"parallel":           {"4"},
+		"azure-version":      {"2905.10"},
+		"debug-systemd-unit": {"a.service", "b.service"},
+	}
+	if !reflect.DeepEqual(profile, expected) {
+		t.Errorf("expected %v, got %v", expected, profile)
+	}
+
+	if _, err := ReadProfile(f.Name(), "broken"); err == nil {
+		t.Errorf("expected an error for a map value")
+	}
+	if _, err := ReadProfile(f.Name(), "missing"); err == nil {
+		t.Errorf("expected an error for a missing profile")
+	}
+}
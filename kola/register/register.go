@@ -17,10 +17,14 @@ package register
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/coreos/go-semver/semver"
 
 	"github.com/coreos/mantle/kola/cluster"
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
 )
 
@@ -33,6 +37,36 @@ const (
 	NoEnableSelinux                   // don't enable selinux when starting or rebooting a machine
 )
 
+// Collector gathers diagnostics from a machine of a failed test. Command
+// is run on the machine and its output is saved as <Name>.txt in the
+// diagnostics directory of the machine.
+type Collector struct {
+	Name    string
+	Command string
+}
+
+// Axis is a parameter of a test matrix, such as the filesystem a test is
+// run with. "$" followed by the name of the axis is replaced by the name
+// of each value in the test name, or the name is appended to the test
+// name if it does not contain it, and by the value in the UserData.
+type Axis struct {
+	Name   string
+	Values []Param
+}
+
+// Param is a value of an Axis. The constraints of a value are added to
+// the constraints of the test when the matrix is expanded.
+type Param struct {
+	Name             string            // used in the name of the expanded test
+	Value            string            // replaces the axis in UserData -- defaults to Name
+	Subst            map[string]string // further replacements in UserData, in the order of their keys
+	UserData         *conf.UserData    // replaces the UserData of the test if set
+	Platforms        []string          // whitelist of platforms -- defaults to all
+	ExcludePlatforms []string          // blacklist of platforms -- defaults to none
+	MinVersion       semver.Version
+	EndVersion       semver.Version
+}
+
 // Test provides the main test abstraction for kola. The run function is
 // the actual testing function while the other fields provide ways to
 // statically declare state of the platform.TestCluster before the test
@@ -54,11 +88,48 @@ type Test struct {
 	ExcludeOfferings []string // blacklist of offerings to ignore -- defaults to none
 	Architectures    []string // whitelist of machine architectures supported -- defaults to all
 	Flags            []Flag   // special-case options for this test
+	Tags             []string // labels such as "smoke" or "slow" to select tests by -- defaults to none
+
+	// RequiredFeatures are the features of the platform the test
+	// needs, such as platform.FeatureMultipleDisks. The test is
+	// skipped on platforms lacking any of them.
+	RequiredFeatures []platform.Feature
+
+	// MachineOptions are the memory, CPUs, additional disks and NICs
+	// the machines of the test need beyond the default machine of the
+	// platform. Platforms pick a big enough instance type, and the
+	// test is skipped on those which cannot add the disks or NICs.
+	MachineOptions platform.MachineOptions
 
 	// FailFast skips any sub-test that occurs after a sub-test has
 	// failed.
 	FailFast bool
 
+	// Retries is the number of times a failed test is run again on a
+	// fresh cluster. A test which passes on a retry is reported as
+	// flaky instead of failed.
+	Retries int
+
+	// Collectors gather diagnostics from the machines of the test if
+	// it fails, in addition to the ones kola runs for every test.
+	Collectors []Collector
+
+	// ExpectedConsoleRules names the console rules, such as
+	// "kernel-warning", which the test is expected to trigger. Their
+	// matches in the console and journal of its machines are ignored.
+	ExpectedConsoleRules []string
+
+	// SharedCluster allows the test to run on the machines of other
+	// tests with SharedCluster set and the same UserData, UserDataV3,
+	// ClusterSize, Flags and MachineOptions, one test after another.
+	// Only tests which do not modify their machines may set it.
+	SharedCluster bool
+
+	// Timeout is the maximum duration of the test function, not counting
+	// the creation of its cluster. When it is exceeded the test fails and
+	// its cluster is torn down. Zero means no timeout.
+	Timeout time.Duration
+
 	// MinVersion prevents the test from executing on CoreOS machines
 	// less than MinVersion. This will be ignored if the name fully
 	// matches without globbing.
@@ -68,6 +139,15 @@ type Test struct {
 	// greater than or equal to EndVersion. This will be ignored if
 	// the name fully matches without globbing.
 	EndVersion semver.Version
+
+	// Matrix expands the test into one test for every combination of
+	// the values of its axes. Register registers the expanded tests
+	// instead of the test itself.
+	Matrix []Axis
+
+	// Params maps the axes of the matrix the test was expanded from to
+	// their values. It is set by Register.
+	Params map[string]string
 }
 
 // Registered tests live here. Mapping of names to tests.
@@ -77,6 +157,13 @@ var Tests = map[string]*Test{}
 // harnesses knows which tests it can choose from. Panics if existing
 // name is registered
 func Register(t *Test) {
+	if len(t.Matrix) > 0 {
+		for _, et := range expandMatrix(t) {
+			Register(et)
+		}
+		return
+	}
+
 	_, ok := Tests[t.Name]
 	if ok {
 		panic(fmt.Sprintf("test %v already registered", t.Name))
@@ -89,6 +176,107 @@ func Register(t *Test) {
 	Tests[t.Name] = t
 }
 
+// expandMatrix returns a test for every combination of the values of the
+// axes of t.
+func expandMatrix(t *Test) []*Test {
+	combinations := [][]Param{nil}
+	for _, axis := range t.Matrix {
+		if len(axis.Values) == 0 {
+			panic(fmt.Sprintf("test %v: axis %v has no values", t.Name, axis.Name))
+		}
+		var expanded [][]Param
+		for _, prev := range combinations {
+			for _, p := range axis.Values {
+				expanded = append(expanded, append(append([]Param(nil), prev...), p))
+			}
+		}
+		combinations = expanded
+	}
+
+	var tests []*Test
+	for _, params := range combinations {
+		tests = append(tests, applyParams(t, params))
+	}
+	return tests
+}
+
+// applyParams returns a copy of t for the given value of every axis of
+// its matrix.
+func applyParams(t *Test, params []Param) *Test {
+	nt := *t
+	nt.Matrix = nil
+	nt.Params = map[string]string{}
+	nt.ExcludePlatforms = append([]string(nil), t.ExcludePlatforms...)
+
+	var subst [][2]string
+	for i, p := range params {
+		axis := t.Matrix[i].Name
+		value := p.Value
+		if value == "" {
+			value = p.Name
+		}
+		nt.Params[axis] = value
+
+		placeholder := "$" + axis
+		if strings.Contains(nt.Name, placeholder) {
+			nt.Name = strings.Replace(nt.Name, placeholder, p.Name, -1)
+		} else {
+			nt.Name += "." + p.Name
+		}
+
+		if p.UserData != nil {
+			nt.UserData = p.UserData
+		}
+		subst = append(subst, [2]string{placeholder, value})
+		var olds []string
+		for old := range p.Subst {
+			olds = append(olds, old)
+		}
+		sort.Strings(olds)
+		for _, old := range olds {
+			subst = append(subst, [2]string{old, p.Subst[old]})
+		}
+
+		if len(p.Platforms) > 0 {
+			if len(nt.Platforms) == 0 {
+				nt.Platforms = append([]string(nil), p.Platforms...)
+			} else {
+				var platforms []string
+				for _, platform := range nt.Platforms {
+					for _, allowed := range p.Platforms {
+						if platform == allowed {
+							platforms = append(platforms, platform)
+						}
+					}
+				}
+				if len(platforms) == 0 {
+					panic(fmt.Sprintf("test %v has no platforms left", nt.Name))
+				}
+				nt.Platforms = platforms
+			}
+		}
+		nt.ExcludePlatforms = append(nt.ExcludePlatforms, p.ExcludePlatforms...)
+
+		if nt.MinVersion.LessThan(p.MinVersion) {
+			nt.MinVersion = p.MinVersion
+		}
+		if (p.EndVersion != semver.Version{}) && ((nt.EndVersion == semver.Version{}) || p.EndVersion.LessThan(nt.EndVersion)) {
+			nt.EndVersion = p.EndVersion
+		}
+	}
+
+	// substitute after all axes had the chance to replace the UserData
+	for _, s := range subst {
+		if nt.UserData != nil {
+			nt.UserData = nt.UserData.Subst(s[0], s[1])
+		}
+		if nt.UserDataV3 != nil {
+			nt.UserDataV3 = nt.UserDataV3.Subst(s[0], s[1])
+		}
+	}
+	return &nt
+}
+
 func (t *Test) HasFlag(flag Flag) bool {
 	for _, f := range t.Flags {
 		if f == flag {
@@ -97,3 +285,34 @@ func (t *Test) HasFlag(flag Flag) bool {
 	}
 	return false
 }
+
+// MissingFeatures returns the features required by the test or its machine
+// options which are not in features.
+func (t *Test) MissingFeatures(features []platform.Feature) []platform.Feature {
+	var missing []platform.Feature
+	required := append(t.MachineOptions.RequiredFeatures(), t.RequiredFeatures...)
+	for _, required := range required {
+		found := false
+		for _, f := range features {
+			if f == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+	return missing
+}
+
+// ExpectsConsoleRule reports whether the test is expected to trigger the
+// named console rule.
+func (t *Test) ExpectsConsoleRule(name string) bool {
+	for _, rule := range t.ExpectedConsoleRules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package register
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	test := &Test{
+		Name:      "cl.matrix.$fs",
+		UserData:  conf.Ignition(`{"format": "$fs", "version": "$ign", "options": "$options"}`),
+		Platforms: []string{"qemu", "aws"},
+		Matrix: []Axis{
+			{
+				Name: "fs",
+				Values: []Param{
+					{Name: "ext4", Subst: map[string]string{"$options": "-U"}},
+					{Name: "xfs", Platforms: []string{"aws", "gce"}, MinVersion: semver.Version{Major: 2000}},
+				},
+			},
+			{
+				Name: "ign",
+				Values: []Param{
+					{Name: "v2", Value: "2.0.0"},
+					{Name: "v3", Value: "3.0.0", UserData: conf.Ignition(`{"v3": "$fs"}`), ExcludePlatforms: []string{"qemu"}},
+				},
+			},
+		},
+	}
+
+	tests := expandMatrix(test)
+	var names []string
+	for _, et := range tests {
+		names = append(names, et.Name)
+	}
+	expected := []string{"cl.matrix.ext4.v2", "cl.matrix.ext4.v3", "cl.matrix.xfs.v2", "cl.matrix.xfs.v3"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected tests %v, got %v", expected, names)
+	}
+
+	if !tests[0].UserData.Contains(`{"format": "ext4", "version": "2.0.0", "options": "-U"}`) {
+		t.Errorf("unexpected UserData for %v", tests[0].Name)
+	}
+	if !tests[1].UserData.Contains(`{"v3": "ext4"}`) || !reflect.DeepEqual(tests[1].ExcludePlatforms, []string{"qemu"}) {
+		t.Errorf("unexpected test %+v", tests[1])
+	}
+	if !reflect.DeepEqual(tests[2].Params, map[string]string{"fs": "xfs", "ign": "2.0.0"}) {
+		t.Errorf("unexpected params %v", tests[2].Params)
+	}
+	if !reflect.DeepEqual(tests[2].Platforms, []string{"aws"}) || tests[2].MinVersion.Major != 2000 {
+		t.Errorf("unexpected constraints %+v", tests[2])
+	}
+	if len(test.ExcludePlatforms) != 0 || tests[0].Matrix != nil {
+		t.Errorf("expanding modified the test")
+	}
+}
+
+func TestMissingFeatures(t *testing.T) {
+	test := &Test{RequiredFeatures: []platform.Feature{platform.FeatureMultipleDisks, platform.FeatureTPM}}
+	missing := test.MissingFeatures([]platform.Feature{platform.FeatureReboot, platform.FeatureMultipleDisks})
+	if !reflect.DeepEqual(missing, []platform.Feature{platform.FeatureTPM}) {
+		t.Errorf("expected tpm to be missing, got %v", missing)
+	}
+	if missing := (&Test{}).MissingFeatures(nil); len(missing) != 0 {
+		t.Errorf("expected no missing features, got %v", missing)
+	}
+
+	test = &Test{MachineOptions: platform.MachineOptions{MinMemory: 4096, AdditionalNics: 1}}
+	missing = test.MissingFeatures([]platform.Feature{platform.FeatureMultipleDisks})
+	if !reflect.DeepEqual(missing, []platform.Feature{platform.FeatureMultipleNICs}) {
+		t.Errorf("expected multiple-nics to be missing, got %v", missing)
+	}
+}
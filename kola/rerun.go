@@ -0,0 +1,61 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/coreos/mantle/harness/reporters"
+	"github.com/coreos/mantle/harness/testresult"
+)
+
+// RerunPatterns returns the names of the top-level tests which failed in
+// the given JSON report, plus those which were skipped if skipped is set.
+// For a run on several platforms these are the tests which failed on any
+// platform. The names are exact test names and can be passed to
+// FilterTests as patterns.
+func RerunPatterns(report string, skipped bool) ([]string, error) {
+	r, err := reporters.ReadJSONReport(report)
+	if err != nil {
+		return nil, err
+	}
+
+	// tests are subtests of their platform when run on several
+	depth := 0
+	if strings.Contains(r.Platform, ",") {
+		depth = 1
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, t := range r.Tests {
+		// subtests are reported before and rolled up into their parent
+		parts := strings.Split(t.Name, "/")
+		if len(parts) != depth+1 {
+			continue
+		}
+		name := parts[depth]
+		if seen[name] {
+			continue
+		}
+		if t.Result == testresult.Fail || (skipped && t.Result == testresult.Skip) {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/mantle/harness/reporters"
+	"github.com/coreos/mantle/kola/register"
+)
+
+// ParseShard parses a shard specification of the form "i/n" where
+// 1 <= i <= n.
+func ParseShard(s string) (index, count int, err error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid shard %q: expected i/n", s)
+	}
+	if index, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %v", s, err)
+	}
+	if count, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %v", s, err)
+	}
+	if count < 1 || index < 1 || index > count {
+		return 0, 0, fmt.Errorf("invalid shard %q: index must be between 1 and %d", s, count)
+	}
+	return index, count, nil
+}
+
+// ReadDurations returns the durations of the tests run on pltfrm recorded
+// in the given JSON reports. In reports of runs on several platforms the
+// tests are subtests of their platform. If a test appears in several
+// reports the longest duration is used.
+func ReadDurations(files []string, pltfrm string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, file := range files {
+		report, err := reporters.ReadJSONReport(file)
+		if err != nil {
+			return nil, err
+		}
+		multi := strings.Contains(report.Platform, ",")
+		if !multi && report.Platform != "" && report.Platform != pltfrm {
+			continue
+		}
+		for _, t := range report.Tests {
+			name := t.Name
+			if multi {
+				parts := strings.SplitN(name, "/", 2)
+				if len(parts) != 2 || parts[0] != pltfrm {
+					continue
+				}
+				name = parts[1]
+			}
+			if strings.Contains(name, "/") {
+				continue
+			}
+			if t.Duration > durations[name] {
+				durations[name] = t.Duration
+			}
+		}
+	}
+	return durations, nil
+}
+
+// ShardTests returns the tests belonging to shard index (counting from 1)
+// of count shards. The assignment only depends on the test names and the
+// given durations, so invocations using the same arguments for every
+// index cover all tests exactly once.
+//
+// Tests are assigned longest first to the shard with the least total
+// duration. Tests without a recorded duration are assumed to take the
+// average of the known durations. Without any durations this degrades to
+// dealing the tests out round-robin by name.
+func ShardTests(tests map[string]*register.Test, index, count int, durations map[string]time.Duration) map[string]*register.Test {
+	if count <= 1 {
+		return tests
+	}
+
+	names, weight := sortByDuration(tests, durations)
+	loads := make([]time.Duration, count)
+	r := make(map[string]*register.Test)
+	for _, name := range names {
+		shard := 0
+		for i := range loads {
+			if loads[i] < loads[shard] {
+				shard = i
+			}
+		}
+		loads[shard] += weight(name)
+		if shard == index-1 {
+			r[name] = tests[name]
+		}
+	}
+	return r
+}
+
+// ScheduleTests returns the names of the tests in the order they should
+// start, longest first, so long tests do not start late and stretch the
+// run. Tests without a recorded duration are assumed to take the average
+// of the known durations. Without any durations the tests are sorted by
+// name.
+func ScheduleTests(tests map[string]*register.Test, durations map[string]time.Duration) []string {
+	names, _ := sortByDuration(tests, durations)
+	return names
+}
+
+// sortByDuration returns the names of the tests sorted longest first, ties
+// broken by name, and the duration assumed for each test.
+func sortByDuration(tests map[string]*register.Test, durations map[string]time.Duration) ([]string, func(string) time.Duration) {
+	var known time.Duration
+	var nknown int
+	for name := range tests {
+		if d, ok := durations[name]; ok {
+			known += d
+			nknown++
+		}
+	}
+	average := time.Duration(1)
+	if nknown > 0 && known > 0 {
+		average = known / time.Duration(nknown)
+	}
+	weight := func(name string) time.Duration {
+		if d, ok := durations[name]; ok && d > 0 {
+			return d
+		}
+		return average
+	}
+
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		wi, wj := weight(names[i]), weight(names[j])
+		if wi != wj {
+			return wi > wj
+		}
+		return names[i] < names[j]
+	})
+	return names, weight
+}
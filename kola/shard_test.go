@@ -0,0 +1,119 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/mantle/kola/register"
+)
+
+func TestParseShard(t *testing.T) {
+	for _, tt := range []struct {
+		in           string
+		index, count int
+		ok           bool
+	}{
+		{"1/1", 1, 1, true},
+		{"2/3", 2, 3, true},
+		{"0/3", 0, 0, false},
+		{"4/3", 0, 0, false},
+		{"1", 0, 0, false},
+		{"a/b", 0, 0, false},
+	} {
+		index, count, err := ParseShard(tt.in)
+		if (err == nil) != tt.ok || index != tt.index || count != tt.count {
+			t.Errorf("ParseShard(%q) = %d, %d, %v", tt.in, index, count, err)
+		}
+	}
+}
+
+func TestShardTests(t *testing.T) {
+	tests := make(map[string]*register.Test)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("test%d", i)
+		tests[name] = &register.Test{Name: name}
+	}
+	durations := map[string]time.Duration{
+		"test0": 10 * time.Minute,
+		"test1": 5 * time.Minute,
+		"test2": 5 * time.Minute,
+	}
+
+	for _, d := range []map[string]time.Duration{nil, durations} {
+		const count = 3
+		seen := make(map[string]int)
+		for index := 1; index <= count; index++ {
+			shard := ShardTests(tests, index, count, d)
+			again := ShardTests(tests, index, count, d)
+			if len(shard) != len(again) {
+				t.Errorf("shard %d/%d is not deterministic", index, count)
+			}
+			for name := range shard {
+				seen[name]++
+			}
+		}
+		for name := range tests {
+			if seen[name] != 1 {
+				t.Errorf("%s is in %d shards, expected 1", name, seen[name])
+			}
+		}
+	}
+
+	// The longest test gets a shard of its own.
+	tests = map[string]*register.Test{
+		"long":   {Name: "long"},
+		"short1": {Name: "short1"},
+		"short2": {Name: "short2"},
+		"short3": {Name: "short3"},
+	}
+	durations = map[string]time.Duration{
+		"long":   10 * time.Minute,
+		"short1": 5 * time.Minute,
+		"short2": 3 * time.Minute,
+		"short3": 2 * time.Minute,
+	}
+	if shard := ShardTests(tests, 1, 3, durations); len(shard) != 1 || shard["long"] == nil {
+		t.Errorf("expected shard 1/3 to only contain long, got %v", shard)
+	}
+}
+
+func TestScheduleTests(t *testing.T) {
+	tests := map[string]*register.Test{
+		"a": {Name: "a"},
+		"b": {Name: "b"},
+		"c": {Name: "c"},
+		"d": {Name: "d"},
+	}
+	durations := map[string]time.Duration{
+		"b": 1 * time.Minute,
+		"c": 10 * time.Minute,
+	}
+	for _, tc := range []struct {
+		durations map[string]time.Duration
+		want      string
+	}{
+		{nil, "a,b,c,d"},
+		// a and d are assumed to take the average, 5m30s
+		{durations, "c,a,d,b"},
+	} {
+		if got := strings.Join(ScheduleTests(tests, tc.durations), ","); got != tc.want {
+			t.Errorf("got order %s, want %s", got, tc.want)
+		}
+	}
+}
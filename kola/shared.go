@@ -0,0 +1,249 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coreos/mantle/harness"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+)
+
+// sharedClusterKey is what tests must have in common to share a cluster.
+type sharedClusterKey struct {
+	userData       *conf.UserData
+	userDataV3     *conf.UserData
+	clusterSize    int
+	flags          []register.Flag
+	machineOptions platform.MachineOptions
+}
+
+// consoleMarker starts the line written to the consoles of a shared cluster
+// when a test starts on it, followed by the name of the test.
+const consoleMarker = "kola: starting "
+
+// sharedCluster is a cluster shared by tests with SharedCluster set and
+// the same sharedClusterKey. The tests run on it one after another, the
+// first one creates it and the last one destroys it. A test which fails
+// discards it, so the next one creates a fresh cluster.
+//
+// The machines save their artifacts in the output directory of the shared
+// cluster. Each test checks the part of the journal written while it ran
+// right away, and the part of the console once the cluster is gone since
+// most platforms only provide the console then; both parts are saved in
+// the output directory of the test.
+type sharedCluster struct {
+	key       sharedClusterKey
+	outputDir string
+
+	// mu is held by the test running on the cluster.
+	mu       sync.Mutex
+	cluster  platform.Cluster      // nil if there is no cluster to reuse
+	consoles *sharedConsoles       // of the cluster being used or reused
+	users    int                   // tests which have not finished yet
+	finished map[string]bool       // tests which have called done
+	ran      map[string]*sharedRun // tests yet to check their console
+
+	// offset of the output of the running test in the journal of each
+	// machine
+	journalStart map[string]int
+}
+
+// sharedConsoles are the console output of the machines of a shared
+// cluster, available once the cluster is gone.
+type sharedConsoles struct {
+	ready   sync.RWMutex      // locked until the cluster is gone
+	outputs map[string]string // nil if the cluster was kept
+}
+
+// sharedRun is the cluster a test left to the next one, whose console it
+// checks once the cluster is gone.
+type sharedRun struct {
+	consoles *sharedConsoles
+	marker   string // line marking the start of the test, "" on new machines
+}
+
+// groupSharedClusters returns the shared cluster of every test with
+// SharedCluster set, by test name. The shared clusters save the artifacts
+// of their machines below outputDir.
+func groupSharedClusters(tests map[string]*register.Test, outputDir string) map[string]*sharedCluster {
+	var names []string
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var clusters []*sharedCluster
+	ret := make(map[string]*sharedCluster)
+	for _, name := range names {
+		t := tests[name]
+		if !t.SharedCluster || t.ClusterSize == 0 {
+			continue
+		}
+		key := sharedClusterKey{
+			userData:       t.UserData,
+			userDataV3:     t.UserDataV3,
+			clusterSize:    t.ClusterSize,
+			flags:          t.Flags,
+			machineOptions: t.MachineOptions,
+		}
+		var sc *sharedCluster
+		for _, c := range clusters {
+			if reflect.DeepEqual(c.key, key) {
+				sc = c
+				break
+			}
+		}
+		if sc == nil {
+			sc = &sharedCluster{
+				key:       key,
+				outputDir: filepath.Join(outputDir, "_shared", fmt.Sprint(len(clusters))),
+				finished:  make(map[string]bool),
+				ran:       make(map[string]*sharedRun),
+			}
+			clusters = append(clusters, sc)
+		}
+		sc.users++
+		ret[name] = sc
+	}
+	return ret
+}
+
+// start is called once test t has got cluster c, either a new one or the
+// one of the previous test. It marks where the output of the test begins.
+func (sc *sharedCluster) start(h *harness.H, t *register.Test, c platform.Cluster, reused bool) {
+	sc.journalStart = make(map[string]int)
+	if !reused {
+		sc.consoles = &sharedConsoles{}
+		sc.consoles.ready.Lock()
+		sc.ran[t.Name] = &sharedRun{consoles: sc.consoles}
+		return
+	}
+	run := &sharedRun{
+		consoles: sc.consoles,
+		marker:   consoleMarker + t.Name + "\n",
+	}
+	sc.ran[t.Name] = run
+	for _, m := range c.Machines() {
+		sc.journalStart[m.ID()] = len(m.JournalOutput())
+		cmd := fmt.Sprintf("printf %%s %s | sudo tee /dev/console >/dev/null", shellQuote(run.marker))
+		if _, stderr, err := m.SSH(cmd); err != nil {
+			h.Logf("Marking the start of the test on the console of machine %s: %v: %s", m.ID(), err, stderr)
+		}
+	}
+}
+
+// checkJournal saves the journal of the machines of c written since test t
+// started in the output directory of h and reports the problems found in
+// it.
+func (sc *sharedCluster) checkJournal(h *harness.H, t *register.Test, c platform.Cluster) {
+	for _, m := range c.Machines() {
+		journal := m.JournalOutput()
+		if start := sc.journalStart[m.ID()]; start <= len(journal) {
+			journal = journal[start:]
+		}
+		saveArtifact(h, m.ID(), "journal.txt", journal)
+		reportConsoleProblems(h, FindConsoleProblems([]byte(journal), t), "machine "+m.ID()+" journal")
+	}
+}
+
+// release is called once the cluster used by the running test is gone or
+// kept, so that the tests which ran on it can check their part of the
+// console.
+func (sc *sharedCluster) release(c platform.Cluster, destroyed bool) {
+	if destroyed {
+		sc.consoles.outputs = c.ConsoleOutput()
+	}
+	sc.consoles.ready.Unlock()
+	sc.consoles = nil
+}
+
+// checkConsole waits until the cluster test t ran on is gone, then saves
+// the part of its console written while t ran in the output directory of h
+// and reports the problems found in it.
+func (sc *sharedCluster) checkConsole(h *harness.H, t *register.Test) {
+	h.Lock(&sc.mu)
+	run := sc.ran[t.Name]
+	delete(sc.ran, t.Name)
+	sc.mu.Unlock()
+	if run == nil {
+		return
+	}
+
+	// wait for the other tests without holding a parallel slot
+	h.Lock(run.consoles.ready.RLocker())
+	defer run.consoles.ready.RUnlock()
+	for id, console := range run.consoles.outputs {
+		if run.marker != "" {
+			i := strings.Index(console, run.marker)
+			if i < 0 {
+				h.Logf("The console of machine %s does not show the start of the test, checking all of it", id)
+			} else {
+				console = console[i+len(run.marker):]
+			}
+		}
+		if i := strings.Index(console, consoleMarker); i >= 0 {
+			console = console[:i]
+		}
+		saveArtifact(h, id, "console.txt", console)
+		reportConsoleProblems(h, FindConsoleProblems([]byte(console), t), "machine "+id+" console")
+	}
+}
+
+// saveArtifact saves output as the named artifact of machine id in the
+// output directory of h.
+func saveArtifact(h *harness.H, id, name, output string) {
+	dir := filepath.Join(h.OutputDir(), id)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		h.Logf("Saving %s of machine %s: %v", name, id, err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(output), 0644); err != nil {
+		h.Logf("Saving %s of machine %s: %v", name, id, err)
+	}
+}
+
+// done is called once test t has finished, whether it ran or not, and
+// again by the harness test running it; only the first call counts. The
+// last test destroys the cluster if remove is set. A test which ran on the
+// cluster then checks its part of the console.
+func (sc *sharedCluster) done(h *harness.H, t *register.Test, remove bool) {
+	h.Lock(&sc.mu)
+	if sc.finished[t.Name] {
+		sc.mu.Unlock()
+		return
+	}
+	sc.finished[t.Name] = true
+	sc.users--
+	if sc.users == 0 && sc.cluster != nil {
+		c := sc.cluster
+		sc.cluster = nil
+		if remove {
+			c.Destroy()
+		}
+		sc.release(c, remove)
+	}
+	sc.mu.Unlock()
+	sc.checkConsole(h, t)
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/mantle/harness"
+	"github.com/coreos/mantle/kola/register"
+	"github.com/coreos/mantle/platform/conf"
+)
+
+func TestGroupSharedClusters(t *testing.T) {
+	tests := map[string]*register.Test{
+		"a":          {ClusterSize: 1, SharedCluster: true},
+		"b":          {ClusterSize: 1, SharedCluster: true},
+		"userdata-1": {ClusterSize: 1, SharedCluster: true, UserData: conf.Ignition(`{"ignition": {"version": "2.0.0"}}`)},
+		"userdata-2": {ClusterSize: 1, SharedCluster: true, UserData: conf.Ignition(`{"ignition": {"version": "2.0.0"}}`)},
+		"flags":      {ClusterSize: 1, SharedCluster: true, Flags: []register.Flag{register.NoSSHKeyInMetadata}},
+		"size":       {ClusterSize: 2, SharedCluster: true},
+		"no-cluster": {ClusterSize: 0, SharedCluster: true},
+		"fresh":      {ClusterSize: 1},
+	}
+
+	shared := groupSharedClusters(tests, "_kola_temp")
+	if len(shared) != 6 {
+		t.Errorf("expected 6 tests on shared clusters, got %d", len(shared))
+	}
+	if shared["a"] != shared["b"] || shared["a"].users != 2 {
+		t.Errorf("expected a and b to share a cluster")
+	}
+	if shared["userdata-1"] != shared["userdata-2"] || shared["userdata-1"] == shared["a"] {
+		t.Errorf("expected tests with equal userdata to share a cluster")
+	}
+	for _, name := range []string{"flags", "size"} {
+		if shared[name] == shared["a"] || shared[name].users != 1 {
+			t.Errorf("expected %s to have its own cluster", name)
+		}
+	}
+	for _, name := range []string{"no-cluster", "fresh"} {
+		if shared[name] != nil {
+			t.Errorf("expected %s not to share a cluster", name)
+		}
+	}
+}
+
+func TestSharedClusterConsole(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a created the cluster, b and c reused it
+	sc := &sharedCluster{ran: make(map[string]*sharedRun)}
+	consoles := &sharedConsoles{outputs: map[string]string{
+		"m1": "boot\n" + consoleMarker + "b\nKernel panic - not syncing: b\n" + consoleMarker + "c\nfine\n",
+	}}
+	for _, name := range []string{"a", "b", "c"} {
+		sc.ran[name] = &sharedRun{consoles: consoles}
+		if name != "a" {
+			sc.ran[name].marker = consoleMarker + name + "\n"
+		}
+	}
+
+	results := make(map[string]bool)
+	check := func(h *harness.H) {
+		sc.checkConsole(h, &register.Test{Name: h.Name()})
+		results[h.Name()] = h.Failed()
+	}
+	suite := harness.NewSuite(harness.Options{
+		OutputDir: filepath.Join(dir, "out"),
+		Parallel:  1,
+	}, harness.Tests{"a": check, "b": check, "c": check})
+	if err := suite.Run(); err != harness.SuiteFailed {
+		t.Errorf("expected the suite to fail, got %v", err)
+	}
+
+	expected := map[string]string{
+		"a": "boot\n",
+		"b": "Kernel panic - not syncing: b\n",
+		"c": "fine\n",
+	}
+	for name, console := range expected {
+		if results[name] != (name == "b") {
+			t.Errorf("%s: expected failed to be %v", name, name == "b")
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, "out", name, "m1", "console.txt"))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if string(data) != console {
+			t.Errorf("%s: expected console %q, got %q", name, console, data)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/coreos/mantle/platform"
+	"github.com/coreos/mantle/platform/conf"
+)
+
+// machineRef is a reference to the address of another machine of a
+// cluster spec in the userdata of a machine, e.g. $private_ip:etcd-2.
+var machineRef = regexp.MustCompile(`\$(ip|private_ip|private_ips):([A-Za-z0-9_-]+)`)
+
+// ClusterSpec describes the machines to spawn with kola spawn --spec.
+type ClusterSpec struct {
+	// Machines are started in order.
+	Machines []MachineSpec `yaml:"machines"`
+}
+
+// MachineSpec describes a group of identical machines of a ClusterSpec.
+// The machines of a group called etcd are called etcd-1, etcd-2 and so on.
+//
+// The userdata can refer to the machines started before: $ip:etcd-2 and
+// $private_ip:etcd-2 are replaced by the public and private IP of etcd-2,
+// $ip:etcd and $private_ip:etcd by those of etcd-1, and $private_ips:etcd
+// by the private IPs of all machines of the group, separated by commas.
+type MachineSpec struct {
+	Name     string     `yaml:"name"`
+	Count    int        `yaml:"count"`    // defaults to 1
+	UserData string     `yaml:"userdata"` // file relative to the spec
+	Memory   int        `yaml:"memory"`   // minimum in MiB
+	CPUs     int        `yaml:"cpus"`     // minimum number of virtual CPUs
+	Disks    []DiskSpec `yaml:"disks"`    // additional disks
+	Nics     int        `yaml:"nics"`     // additional network interfaces
+
+	userdata string
+}
+
+// DiskSpec is an additional disk of the machines of a MachineSpec, see
+// platform.Disk.
+type DiskSpec struct {
+	Size        string   `yaml:"size"`
+	BackingFile string   `yaml:"backing_file"`
+	DeviceOpts  []string `yaml:"device_opts"`
+}
+
+// SpawnedMachine is a machine started from a ClusterSpec.
+type SpawnedMachine struct {
+	Name  string
+	Group string
+	platform.Machine
+}
+
+// ReadClusterSpec reads a YAML or JSON cluster spec and the userdata files
+// it refers to.
+func ReadClusterSpec(file string) (*ClusterSpec, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var spec ClusterSpec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", file, err)
+	}
+	if len(spec.Machines) == 0 {
+		return nil, fmt.Errorf("%v: no machines", file)
+	}
+
+	names := make(map[string]bool)
+	for i := range spec.Machines {
+		ms := &spec.Machines[i]
+		if ms.Name == "" {
+			return nil, fmt.Errorf("%v: every machine needs a name", file)
+		}
+		if ms.Count == 0 {
+			ms.Count = 1
+		} else if ms.Count < 0 {
+			return nil, fmt.Errorf("%v: machine %v: count must be at least 1", file, ms.Name)
+		}
+		for _, name := range append([]string{ms.Name}, ms.machineNames()...) {
+			if names[name] {
+				return nil, fmt.Errorf("%v: more than one machine is called %v", file, name)
+			}
+			names[name] = true
+		}
+		if ms.UserData != "" {
+			b, err := ioutil.ReadFile(filepath.Join(filepath.Dir(file), ms.UserData))
+			if err != nil {
+				return nil, fmt.Errorf("%v: machine %v: %v", file, ms.Name, err)
+			}
+			ms.userdata = string(b)
+		}
+	}
+	return &spec, nil
+}
+
+// machineNames returns the names of the machines of the group.
+func (ms *MachineSpec) machineNames() []string {
+	var names []string
+	for i := 1; i <= ms.Count; i++ {
+		names = append(names, fmt.Sprintf("%s-%d", ms.Name, i))
+	}
+	return names
+}
+
+// machineOptions returns the options to start the machines of the group
+// with.
+func (ms *MachineSpec) machineOptions() platform.MachineOptions {
+	opts := platform.MachineOptions{
+		MinMemory:      ms.Memory,
+		MinCPUs:        ms.CPUs,
+		AdditionalNics: ms.Nics,
+	}
+	for _, d := range ms.Disks {
+		opts.AdditionalDisks = append(opts.AdditionalDisks, platform.Disk{
+			Size:        d.Size,
+			BackingFile: d.BackingFile,
+			DeviceOpts:  d.DeviceOpts,
+		})
+	}
+	return opts
+}
+
+// Spawn starts the machines of the spec in c, one after the other. If
+// prepare is not nil, it is applied to the userdata of every machine
+// before it is started. The machines started so far are returned with any
+// error.
+func (spec *ClusterSpec) Spawn(c platform.Cluster, prepare func(*conf.UserData) *conf.UserData) ([]SpawnedMachine, error) {
+	var spawned []SpawnedMachine
+	for _, ms := range spec.Machines {
+		for _, name := range ms.machineNames() {
+			data, err := substMachineRefs(ms.userdata, spawned)
+			if err != nil {
+				return spawned, fmt.Errorf("userdata of %v: %v", name, err)
+			}
+			var userdata *conf.UserData
+			if data != "" {
+				userdata = conf.Unknown(data)
+			}
+			if prepare != nil {
+				userdata = prepare(userdata)
+			}
+
+			m, err := c.NewMachineWithOptions(userdata, ms.machineOptions())
+			if err != nil {
+				return spawned, fmt.Errorf("spawning %v: %v", name, err)
+			}
+			spawned = append(spawned, SpawnedMachine{Name: name, Group: ms.Name, Machine: m})
+		}
+	}
+	return spawned, nil
+}
+
+// substMachineRefs replaces the references to machines in data by their
+// addresses.
+func substMachineRefs(data string, spawned []SpawnedMachine) (string, error) {
+	var err error
+	ret := machineRef.ReplaceAllStringFunc(data, func(ref string) string {
+		parts := machineRef.FindStringSubmatch(ref)
+		kind, name := parts[1], parts[2]
+
+		var machines []SpawnedMachine
+		for _, m := range spawned {
+			if m.Name == name {
+				machines = []SpawnedMachine{m}
+				break
+			}
+			if m.Group == name {
+				machines = append(machines, m)
+			}
+		}
+		if len(machines) == 0 {
+			if err == nil {
+				err = fmt.Errorf("%v does not refer to a machine started before", ref)
+			}
+			return ref
+		}
+
+		switch kind {
+		case "ip":
+			return machines[0].IP()
+		case "private_ip":
+			return machines[0].PrivateIP()
+		default:
+			var ips []string
+			for _, m := range machines {
+				ips = append(ips, m.PrivateIP())
+			}
+			return strings.Join(ips, ",")
+		}
+	})
+	return ret, err
+}
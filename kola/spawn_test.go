@@ -0,0 +1,89 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/mantle/platform"
+)
+
+type addrMachine struct {
+	platform.Machine
+	ip, privateIP string
+}
+
+func (m addrMachine) IP() string        { return m.ip }
+func (m addrMachine) PrivateIP() string { return m.privateIP }
+
+func TestReadClusterSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"cluster.yaml": "machines:\n- name: etcd\n  count: 2\n  userdata: etcd.yaml\n- name: worker\n  memory: 4096\n  disks: [{size: 5G}]\n",
+		"etcd.yaml":    "etcd:\n  name: '{HOSTNAME}'\n",
+		"dup.yaml":     "machines:\n- name: etcd\n  count: 2\n- name: etcd-2\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spec, err := ReadClusterSpec(filepath.Join(dir, "cluster.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Machines) != 2 || spec.Machines[1].Count != 1 || spec.Machines[0].userdata != files["etcd.yaml"] {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+	opts := spec.Machines[1].machineOptions()
+	if disks := opts.AdditionalDisks; len(disks) != 1 || disks[0].Size != "5G" {
+		t.Errorf("unexpected disks %+v", disks)
+	}
+	if opts.MinMemory != 4096 {
+		t.Errorf("unexpected memory %v", opts.MinMemory)
+	}
+
+	if _, err := ReadClusterSpec(filepath.Join(dir, "dup.yaml")); err == nil {
+		t.Errorf("expected an error for duplicate machine names")
+	}
+}
+
+func TestSubstMachineRefs(t *testing.T) {
+	spawned := []SpawnedMachine{
+		{Name: "etcd-1", Group: "etcd", Machine: addrMachine{ip: "1.1.1.1", privateIP: "10.0.0.1"}},
+		{Name: "etcd-2", Group: "etcd", Machine: addrMachine{ip: "1.1.1.2", privateIP: "10.0.0.2"}},
+	}
+
+	data, err := substMachineRefs("$ip:etcd $private_ip:etcd-2:2379 $private_ips:etcd.", spawned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "1.1.1.1 10.0.0.2:2379 10.0.0.1,10.0.0.2."; data != expected {
+		t.Errorf("expected %q, got %q", expected, data)
+	}
+
+	if _, err := substMachineRefs("$ip:worker", spawned); err == nil {
+		t.Errorf("expected an error for a machine not started yet")
+	}
+}
@@ -0,0 +1,366 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/coreos/mantle/platform"
+	awsapi "github.com/coreos/mantle/platform/api/aws"
+	azureapi "github.com/coreos/mantle/platform/api/azure"
+	doapi "github.com/coreos/mantle/platform/api/do"
+	esxapi "github.com/coreos/mantle/platform/api/esx"
+	gcloudapi "github.com/coreos/mantle/platform/api/gcloud"
+	openstackapi "github.com/coreos/mantle/platform/api/openstack"
+	packetapi "github.com/coreos/mantle/platform/api/packet"
+)
+
+// State lists the clusters kola left running, so they can be found and
+// destroyed by later invocations.
+type State struct {
+	Clusters []ClusterState `json:"clusters"`
+}
+
+// ClusterState describes a cluster left running.
+type ClusterState struct {
+	Name      string         `json:"name"`
+	Test      string         `json:"test,omitempty"`
+	Platform  string         `json:"platform"`
+	OutputDir string         `json:"output_dir"`
+	Created   time.Time      `json:"created"`
+	Machines  []MachineState `json:"machines"`
+}
+
+// MachineState describes a machine of a cluster left running.
+type MachineState struct {
+	ID             string `json:"id"`
+	Name           string `json:"name,omitempty"` // given by a spawn spec
+	IP             string `json:"ip"`
+	PrivateIP      string `json:"private_ip,omitempty"`
+	PID            int    `json:"pid,omitempty"`             // qemu process
+	ResourceGroup  string `json:"resource_group,omitempty"`  // azure
+	StorageAccount string `json:"storage_account,omitempty"` // azure
+}
+
+// NewClusterState describes the cluster c and its machines. test is the
+// test which left the cluster running, if any, and outputDir the output
+// directory of the cluster.
+func NewClusterState(c platform.Cluster, test, outputDir string) (ClusterState, error) {
+	outputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return ClusterState{}, err
+	}
+	cs := ClusterState{
+		Name:      c.Name(),
+		Test:      test,
+		Platform:  string(c.Platform()),
+		OutputDir: outputDir,
+		Created:   time.Now().UTC(),
+	}
+	for _, m := range c.Machines() {
+		ms := MachineState{
+			ID:        m.ID(),
+			IP:        m.IP(),
+			PrivateIP: m.PrivateIP(),
+		}
+		if pm, ok := m.(interface{ PID() int }); ok {
+			ms.PID = pm.PID()
+		}
+		if am, ok := m.(interface{ ResourceGroup() string }); ok {
+			ms.ResourceGroup = am.ResourceGroup()
+		}
+		if am, ok := m.(interface{ StorageAccount() string }); ok {
+			ms.StorageAccount = am.StorageAccount()
+		}
+		cs.Machines = append(cs.Machines, ms)
+	}
+	return cs, nil
+}
+
+// Cluster returns the cluster with the given name, or nil.
+func (s *State) Cluster(name string) *ClusterState {
+	for i := range s.Clusters {
+		if s.Clusters[i].Name == name {
+			return &s.Clusters[i]
+		}
+	}
+	return nil
+}
+
+// Machine returns the machine with the given ID or name and its cluster,
+// or nils.
+func (s *State) Machine(name string) (*ClusterState, *MachineState) {
+	for i := range s.Clusters {
+		c := &s.Clusters[i]
+		for j := range c.Machines {
+			if c.Machines[j].ID == name || c.Machines[j].Name == name {
+				return c, &c.Machines[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Remove removes the cluster with the given name.
+func (s *State) Remove(name string) {
+	clusters := s.Clusters[:0]
+	for _, c := range s.Clusters {
+		if c.Name != name {
+			clusters = append(clusters, c)
+		}
+	}
+	s.Clusters = clusters
+}
+
+// ReadState reads the state file. A missing file is an empty state.
+func ReadState(file string) (*State, error) {
+	var s State
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", file, err)
+	}
+	return &s, nil
+}
+
+// UpdateState reads the state file, passes the state to update and writes
+// it back unless update fails. The file is locked meanwhile so concurrent
+// kola invocations can share it.
+func UpdateState(file string, update func(*State) error) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(file+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("locking %v: %v", file, err)
+	}
+
+	s, err := ReadState(file)
+	if err != nil {
+		return err
+	}
+	if err := update(s); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// DestroyCluster terminates the machines of a cluster left running. The
+// platform options must grant access to the account and region the
+// cluster was created in.
+func DestroyCluster(c ClusterState) error {
+	switch c.Platform {
+	case "aws":
+		api, err := awsapi.New(&AWSOptions)
+		if err != nil {
+			return err
+		}
+		var ids []string
+		for _, m := range c.Machines {
+			ids = append(ids, m.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return api.TerminateInstances(ids)
+	case "azure":
+		api, err := azureapi.New(&AzureOptions)
+		if err != nil {
+			return err
+		}
+		if err := api.SetupClients(); err != nil {
+			return err
+		}
+		groups := make(map[string]bool)
+		for _, m := range c.Machines {
+			if m.ResourceGroup != "" && !groups[m.ResourceGroup] {
+				groups[m.ResourceGroup] = true
+				if err := api.TerminateResourceGroup(m.ResourceGroup); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "do":
+		api, err := doapi.New(&DOOptions)
+		if err != nil {
+			return err
+		}
+		for _, m := range c.Machines {
+			id, err := strconv.Atoi(m.ID)
+			if err != nil {
+				return fmt.Errorf("invalid droplet ID %q", m.ID)
+			}
+			if err := api.DeleteDroplet(context.TODO(), id); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "esx":
+		api, err := esxapi.New(&ESXOptions)
+		if err != nil {
+			return err
+		}
+		for _, m := range c.Machines {
+			if err := api.TerminateDevice(m.ID); err != nil {
+				return err
+			}
+			if err := api.CleanupDevice(m.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "gce":
+		api, err := gcloudapi.New(&GCEOptions)
+		if err != nil {
+			return err
+		}
+		for _, m := range c.Machines {
+			if err := api.TerminateInstance(m.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "openstack":
+		api, err := openstackapi.New(&OpenStackOptions)
+		if err != nil {
+			return err
+		}
+		for _, m := range c.Machines {
+			if err := api.DeleteServer(m.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "packet":
+		api, err := packetapi.New(&PacketOptions)
+		if err != nil {
+			return err
+		}
+		for _, m := range c.Machines {
+			if err := api.DeleteDevice(m.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "qemu-unpriv":
+		for _, m := range c.Machines {
+			if err := killQEMU(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("cannot destroy clusters on platform %q", c.Platform)
+	}
+}
+
+// MachineConsole returns the console output of a machine of a cluster left
+// running. The platform options must grant access to the account and
+// region the cluster was created in.
+func MachineConsole(c ClusterState, m MachineState) (string, error) {
+	switch c.Platform {
+	case "aws":
+		api, err := awsapi.New(&AWSOptions)
+		if err != nil {
+			return "", err
+		}
+		return api.GetConsoleOutput(m.ID)
+	case "azure":
+		api, err := azureapi.New(&AzureOptions)
+		if err != nil {
+			return "", err
+		}
+		if err := api.SetupClients(); err != nil {
+			return "", err
+		}
+		if m.StorageAccount == "" {
+			return "", fmt.Errorf("no storage account recorded for machine %v", m.ID)
+		}
+		console, err := api.GetConsoleOutput(m.ID, m.ResourceGroup, m.StorageAccount)
+		return string(console), err
+	case "esx":
+		api, err := esxapi.New(&ESXOptions)
+		if err != nil {
+			return "", err
+		}
+		return api.GetConsoleOutput(m.ID)
+	case "gce":
+		api, err := gcloudapi.New(&GCEOptions)
+		if err != nil {
+			return "", err
+		}
+		return api.GetConsoleOutput(m.ID)
+	case "openstack":
+		api, err := openstackapi.New(&OpenStackOptions)
+		if err != nil {
+			return "", err
+		}
+		return api.GetConsoleOutput(m.ID)
+	case "qemu-unpriv":
+		console, err := ioutil.ReadFile(filepath.Join(c.OutputDir, m.ID, "console.txt"))
+		return string(console), err
+	default:
+		return "", fmt.Errorf("cannot get the console of machines on platform %q", c.Platform)
+	}
+}
+
+// killQEMU kills the qemu process of m if it is still running. The
+// process is identified by the machine ID on its command line, so an
+// unrelated process which reused the PID is left alone.
+func killQEMU(m MachineState) error {
+	if m.PID == 0 {
+		return fmt.Errorf("no qemu PID recorded for machine %v", m.ID)
+	}
+	cmdline, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(m.PID), "cmdline"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !bytes.Contains(cmdline, []byte(m.ID)) {
+		return nil
+	}
+	if err := syscall.Kill(m.PID, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("killing qemu process %v of machine %v: %v", m.PID, m.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kola-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "kola", "state.json")
+
+	for _, cs := range []ClusterState{
+		{Name: "kept", Test: "cl.basic", Platform: "aws", Machines: []MachineState{{ID: "i-1"}}},
+		{Name: "spawned", Platform: "gce", Machines: []MachineState{{ID: "kola-1", Name: "etcd-1"}, {ID: "kola-2", Name: "etcd-2"}}},
+	} {
+		cs := cs
+		if err := UpdateState(file, func(s *State) error {
+			s.Clusters = append(s.Clusters, cs)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := ReadState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, m := s.Machine("etcd-2"); c == nil || c.Name != "spawned" || m.ID != "kola-2" {
+		t.Errorf("etcd-2: unexpected cluster %+v and machine %+v", c, m)
+	}
+	if c, m := s.Machine("i-1"); c == nil || c.Name != "kept" || m.ID != "i-1" {
+		t.Errorf("i-1: unexpected cluster %+v and machine %+v", c, m)
+	}
+	if _, m := s.Machine("etcd-3"); m != nil {
+		t.Errorf("etcd-3: unexpected machine %+v", m)
+	}
+
+	s.Remove("kept")
+	if len(s.Clusters) != 1 || s.Cluster("kept") != nil {
+		t.Errorf("unexpected clusters after removal: %+v", s.Clusters)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TagExpr is a boolean expression over the tags of a test.
+type TagExpr interface {
+	// Match reports whether the expression is true for a test with
+	// the given tags.
+	Match(tags []string) bool
+}
+
+type tagAll struct{}
+
+func (tagAll) Match(tags []string) bool { return true }
+
+type tagName string
+
+func (e tagName) Match(tags []string) bool {
+	for _, tag := range tags {
+		if tag == string(e) {
+			return true
+		}
+	}
+	return false
+}
+
+type tagNot struct{ e TagExpr }
+
+func (e tagNot) Match(tags []string) bool { return !e.e.Match(tags) }
+
+type tagAnd struct{ l, r TagExpr }
+
+func (e tagAnd) Match(tags []string) bool { return e.l.Match(tags) && e.r.Match(tags) }
+
+type tagOr struct{ l, r TagExpr }
+
+func (e tagOr) Match(tags []string) bool { return e.l.Match(tags) || e.r.Match(tags) }
+
+// ParseTagExpr parses a boolean expression of tags such as
+// "storage && !slow" or "smoke || (network && !aws)". Tags consist of
+// letters, digits, '.', '_' and '-'. "!" binds tighter than "&&", which
+// binds tighter than "||". The empty expression matches every test.
+func ParseTagExpr(s string) (TagExpr, error) {
+	p := &tagParser{s: s}
+	p.next()
+	if p.tok == "" {
+		return tagAll{}, nil
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag expression %q: %v", s, err)
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("invalid tag expression %q: unexpected %q", s, p.tok)
+	}
+	return e, nil
+}
+
+// tagParser is a recursive descent parser for tag expressions.
+type tagParser struct {
+	s   string // remaining input
+	tok string // current token, "" at the end
+}
+
+func isTagChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-'
+}
+
+func (p *tagParser) next() {
+	p.s = strings.TrimLeftFunc(p.s, unicode.IsSpace)
+	switch {
+	case p.s == "":
+		p.tok = ""
+	case strings.HasPrefix(p.s, "&&"), strings.HasPrefix(p.s, "||"):
+		p.tok, p.s = p.s[:2], p.s[2:]
+	case strings.IndexFunc(p.s, isTagChar) == 0:
+		end := strings.IndexFunc(p.s, func(r rune) bool { return !isTagChar(r) })
+		if end < 0 {
+			end = len(p.s)
+		}
+		p.tok, p.s = p.s[:end], p.s[end:]
+	default:
+		// single character operators and anything invalid
+		p.tok, p.s = p.s[:1], p.s[1:]
+	}
+}
+
+func (p *tagParser) or() (TagExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "||" {
+		p.next()
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = tagOr{l, r}
+	}
+	return l, nil
+}
+
+func (p *tagParser) and() (TagExpr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "&&" {
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = tagAnd{l, r}
+	}
+	return l, nil
+}
+
+func (p *tagParser) unary() (TagExpr, error) {
+	switch {
+	case p.tok == "!":
+		p.next()
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return tagNot{e}, nil
+	case p.tok == "(":
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.next()
+		return e, nil
+	case p.tok != "" && strings.IndexFunc(p.tok, isTagChar) == 0:
+		e := tagName(p.tok)
+		p.next()
+		return e, nil
+	case p.tok == "":
+		return nil, fmt.Errorf("unexpected end")
+	default:
+		return nil, fmt.Errorf("unexpected %q", p.tok)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kola
+
+import (
+	"testing"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	for _, tt := range []struct {
+		expr  string
+		tags  []string
+		match bool
+	}{
+		{"", nil, true},
+		{"smoke", []string{"smoke"}, true},
+		{"smoke", []string{"slow"}, false},
+		{"storage && !slow", []string{"storage"}, true},
+		{"storage && !slow", []string{"storage", "slow"}, false},
+		{"a || b && c", []string{"a"}, true},
+		{"(a || b) && c", []string{"a"}, false},
+		{"!!a", []string{"a"}, true},
+		{"cl.network-v2", []string{"cl.network-v2"}, true},
+	} {
+		e, err := ParseTagExpr(tt.expr)
+		if err != nil {
+			t.Errorf("ParseTagExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		if match := e.Match(tt.tags); match != tt.match {
+			t.Errorf("%q.Match(%q) = %v, expected %v", tt.expr, tt.tags, match, tt.match)
+		}
+	}
+
+	for _, expr := range []string{"a &&", "(a", "a b", "a & b", "&& a", "a)"} {
+		if _, err := ParseTagExpr(expr); err == nil {
+			t.Errorf("ParseTagExpr(%q) succeeded, expected an error", expr)
+		}
+	}
+}
@@ -28,172 +28,82 @@ const (
 )
 
 func init() {
-	// Reformat the root as btrfs
-	btrfsConfigV1 := conf.Ignition(`{
-		               "ignitionVersion": 1,
-		               "storage": {
-		                   "filesystems": [
-		                       {
-		                           "device": "/dev/disk/by-partlabel/ROOT",
-		                           "format": "btrfs",
-		                           "create": {
-		                               "force": true,
-		                               "options": [
-		                                   "--label=ROOT",
-		                                   "--uuid=` + targetUUID + `"
-		                               ]
-		                           }
-		                       }
-		                   ]
-		               }
-		           }`)
-	btrfsConfigV2 := conf.Ignition(`{
-		               "ignition": {
-		                   "version": "2.0.0"
-		               },
-		               "storage": {
-		                   "filesystems": [
-		                       {
-		                           "mount": {
-		                               "device": "/dev/disk/by-label/ROOT",
-		                               "format": "btrfs",
-		                               "create": {
-		                                   "force": true,
-		                                   "options": [
-		                                       "--label=ROOT",
-		                                       "--uuid=` + targetUUID + `"
-		                                   ]
-		                               }
-		                           }
-		                       }
-		                   ]
-		               }
-		           }`)
+	// Reformat the root as $fs
+	rootConfigV1 := conf.Ignition(`{
+		              "ignitionVersion": 1,
+		              "storage": {
+		                  "filesystems": [
+		                      {
+		                          "device": "/dev/disk/by-partlabel/ROOT",
+		                          "format": "$fs",
+		                          "create": {
+		                              "force": true,
+		                              "options": [$options]
+		                          }
+		                      }
+		                  ]
+		              }
+		          }`)
+	rootConfigV2 := conf.Ignition(`{
+		              "ignition": {
+		                  "version": "2.0.0"
+		              },
+		              "storage": {
+		                  "filesystems": [
+		                      {
+		                          "mount": {
+		                              "device": "$v2device",
+		                              "format": "$fs",
+		                              "create": {
+		                                  "force": true,
+		                                  "options": [$options]
+		                              }
+		                          }
+		                      }
+		                  ]
+		              }
+		          }`)
+	// The v2 btrfs test has always found the root by its filesystem label.
 	register.Register(&register.Test{
-		Name:        "cl.ignition.v1.btrfsroot",
-		Run:         btrfsRoot,
+		Name:        "cl.ignition.$ign.$fsroot",
+		Run:         testRootParam,
 		ClusterSize: 1,
-		UserData:    btrfsConfigV1,
-		Distros:     []string{"cl"},
-	})
-	register.Register(&register.Test{
-		Name:        "cl.ignition.v2.btrfsroot",
-		Run:         btrfsRoot,
-		ClusterSize: 1,
-		UserData:    btrfsConfigV2,
-		Distros:     []string{"cl"},
-	})
-
-	// Reformat the root as xfs
-	xfsConfigV1 := conf.Ignition(`{
-		             "ignitionVersion": 1,
-		             "storage": {
-		                 "filesystems": [
-		                     {
-		                         "device": "/dev/disk/by-partlabel/ROOT",
-		                         "format": "xfs",
-		                         "create": {
-		                             "force": true,
-		                             "options": [
-		                                 "-L", "ROOT",
-		                                 "-m", "uuid=` + targetUUID + `"
-		                             ]
-		                         }
-		                     }
-		                 ]
-		             }
-		         }`)
-	xfsConfigV2 := conf.Ignition(`{
-		             "ignition": {
-		                 "version": "2.0.0"
-		             },
-		             "storage": {
-		                 "filesystems": [
-		                     {
-		                         "mount": {
-		                             "device": "/dev/disk/by-partlabel/ROOT",
-		                             "format": "xfs",
-		                             "create": {
-		                                 "force": true,
-		                                 "options": [
-		                                     "-L", "ROOT",
-		                                     "-m", "uuid=` + targetUUID + `"
-		                                 ]
-		                             }
-		                         }
-		                     }
-		                 ]
-		             }
-		         }`)
-	register.Register(&register.Test{
-		Name:        "cl.ignition.v1.xfsroot",
-		Run:         xfsRoot,
-		ClusterSize: 1,
-		UserData:    xfsConfigV1,
-		Distros:     []string{"cl"},
-	})
-	register.Register(&register.Test{
-		Name:        "cl.ignition.v2.xfsroot",
-		Run:         xfsRoot,
-		ClusterSize: 1,
-		UserData:    xfsConfigV2,
-		Distros:     []string{"cl"},
-	})
-
-	// Reformat the root as ext4
-	ext4ConfigV1 := conf.Ignition(`{
-		             "ignitionVersion": 1,
-		             "storage": {
-		                 "filesystems": [
-		                     {
-		                         "device": "/dev/disk/by-partlabel/ROOT",
-		                         "format": "ext4",
-		                         "create": {
-		                             "force": true,
-		                             "options": [
-		                                 "-L", "ROOT",
-		                                 "-U", "` + targetUUID + `"
-		                             ]
-		                         }
-		                     }
-		                 ]
-		             }
-		         }`)
-	ext4ConfigV2 := conf.Ignition(`{
-		             "ignition": {
-		                 "version": "2.0.0"
-		             },
-		             "storage": {
-		                 "filesystems": [
-		                     {
-		                         "mount": {
-		                             "device": "/dev/disk/by-partlabel/ROOT",
-		                             "format": "ext4",
-		                             "create": {
-		                                 "force": true,
-		                                 "options": [
-		                                     "-L", "ROOT",
-		                                     "-U", "` + targetUUID + `"
-		                                 ]
-		                             }
-		                         }
-		                     }
-		                 ]
-		             }
-		         }`)
-	register.Register(&register.Test{
-		Name:        "cl.ignition.v1.ext4root",
-		Run:         ext4Root,
-		ClusterSize: 1,
-		UserData:    ext4ConfigV1,
-		Distros:     []string{"cl"},
-	})
-	register.Register(&register.Test{
-		Name:        "cl.ignition.v2.ext4root",
-		Run:         ext4Root,
-		ClusterSize: 1,
-		UserData:    ext4ConfigV2,
 		Distros:     []string{"cl"},
+		Matrix: []register.Axis{
+			{
+				Name: "ign",
+				Values: []register.Param{
+					{Name: "v1", UserData: rootConfigV1},
+					{Name: "v2", UserData: rootConfigV2},
+				},
+			},
+			{
+				Name: "fs",
+				Values: []register.Param{
+					{
+						Name: "btrfs",
+						Subst: map[string]string{
+							"$options":  `"--label=ROOT", "--uuid=` + targetUUID + `"`,
+							"$v2device": "/dev/disk/by-label/ROOT",
+						},
+					},
+					{
+						Name: "xfs",
+						Subst: map[string]string{
+							"$options":  `"-L", "ROOT", "-m", "uuid=` + targetUUID + `"`,
+							"$v2device": "/dev/disk/by-partlabel/ROOT",
+						},
+					},
+					{
+						Name: "ext4",
+						Subst: map[string]string{
+							"$options":  `"-L", "ROOT", "-U", "` + targetUUID + `"`,
+							"$v2device": "/dev/disk/by-partlabel/ROOT",
+						},
+					},
+				},
+			},
+		},
 	})
 	register.Register(&register.Test{
 		Name:        "cl.ignition.v2_1.ext4checkexisting",
@@ -272,16 +182,8 @@ var ext4NoClobberV2_1 = conf.Ignition(`{
 		            }
 		        }`)
 
-func btrfsRoot(c cluster.TestCluster) {
-	testRoot(c, "btrfs")
-}
-
-func xfsRoot(c cluster.TestCluster) {
-	testRoot(c, "xfs")
-}
-
-func ext4Root(c cluster.TestCluster) {
-	testRoot(c, "ext4")
+func testRootParam(c cluster.TestCluster) {
+	testRoot(c, c.Params["fs"])
 }
 
 func vfatUsrB(c cluster.TestCluster) {
@@ -21,10 +21,11 @@ import (
 
 func init() {
 	register.Register(&register.Test{
-		Run:         AuthVerify,
-		ClusterSize: 1,
-		Name:        "coreos.auth.verify",
-		Distros:     []string{"cl", "fcos", "rhcos"},
+		Run:           AuthVerify,
+		ClusterSize:   1,
+		Name:          "coreos.auth.verify",
+		Distros:       []string{"cl", "fcos", "rhcos"},
+		SharedCluster: true,
 	})
 }
 
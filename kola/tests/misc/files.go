@@ -24,10 +24,11 @@ import (
 
 func init() {
 	register.Register(&register.Test{
-		Run:         Filesystem,
-		ClusterSize: 1,
-		Name:        "cl.filesystem",
-		Distros:     []string{"cl"},
+		Run:           Filesystem,
+		ClusterSize:   1,
+		Name:          "cl.filesystem",
+		Distros:       []string{"cl"},
+		SharedCluster: true,
 	})
 }
 
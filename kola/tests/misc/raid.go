@@ -21,8 +21,6 @@ import (
 	"github.com/coreos/mantle/kola/register"
 	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/platform/conf"
-	"github.com/coreos/mantle/platform/machine/qemu"
-	"github.com/coreos/mantle/platform/machine/unprivqemu"
 )
 
 var (
@@ -61,16 +59,23 @@ var (
 
 func init() {
 	register.Register(&register.Test{
-		// This test needs additional disks which is only supported on qemu since Ignition
-		// does not support deleting partitions without wiping the partition table and the
-		// disk doesn't have room for new partitions.
+		// This test needs an additional disk since Ignition does not
+		// support deleting partitions without wiping the partition table
+		// and the disk doesn't have room for new partitions. The disks
+		// are found by their virtio serials, so the DeviceOpts setting
+		// them need FeatureDiskDeviceOpts.
 		// TODO(ajeddeloh): change this to delete partition 9 and replace it with 9 and 10
 		// once Ignition supports it.
 		Run:         RootOnRaid,
-		ClusterSize: 0,
-		Platforms:   []string{"qemu"},
+		ClusterSize: 1,
 		Name:        "cl.disk.raid.root",
-		Distros:     []string{"cl"},
+		UserData:    raidRootUserData,
+		MachineOptions: platform.MachineOptions{
+			AdditionalDisks: []platform.Disk{
+				{Size: "520M", DeviceOpts: []string{"serial=secondary"}},
+			},
+		},
+		Distros: []string{"cl"},
 	})
 	register.Register(&register.Test{
 		Run:         DataOnRaid,
@@ -106,33 +111,12 @@ systemd:
 }
 
 func RootOnRaid(c cluster.TestCluster) {
-	var m platform.Machine
-	var err error
-	options := platform.MachineOptions{
-		AdditionalDisks: []platform.Disk{
-			{Size: "520M", DeviceOpts: []string{"serial=secondary"}},
-		},
-	}
-	switch pc := c.Cluster.(type) {
-	// These cases have to be separated because when put together to the same case statement
-	// the golang compiler no longer checks that the individual types in the case have the
-	// NewMachineWithOptions function, but rather whether platform.Cluster does which fails
-	case *qemu.Cluster:
-		m, err = pc.NewMachineWithOptions(raidRootUserData, options)
-	case *unprivqemu.Cluster:
-		m, err = pc.NewMachineWithOptions(raidRootUserData, options)
-	default:
-		c.Fatal("unknown cluster type")
-	}
-	if err != nil {
-		c.Fatal(err)
-	}
+	m := c.Machines()[0]
 
 	checkIfMountpointIsRaid(c, m, "/")
 
 	// reboot it to make sure it comes up again
-	err = m.Reboot()
-	if err != nil {
+	if err := m.Reboot(); err != nil {
 		c.Fatalf("could not reboot machine: %v", err)
 	}
 
@@ -24,6 +24,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/util"
 )
 
@@ -44,17 +45,26 @@ func (a *API) DeleteKey(name string) error {
 	return err
 }
 
-// CreateInstances creates EC2 instances with a given name tag, optional ssh key name, user data. The image ID, instance type, and security group set in the API will be used. CreateInstances will block until all instances are running and have an IP address.
-func (a *API) CreateInstances(name, keyname, userdata string, count uint64) ([]*ec2.Instance, error) {
+// CreateInstances creates EC2 instances with a given name tag, optional ssh key name, user data. The image ID, instance type, and security group set in the API will be used, unless options require a bigger instance type. The additional disks of options are attached as EBS volumes. CreateInstances will block until all instances are running and have an IP address.
+func (a *API) CreateInstances(name, keyname, userdata string, count uint64, options platform.MachineOptions) ([]*ec2.Instance, error) {
 	cnt := int64(count)
 
+	instanceType, err := a.selectInstanceType(options)
+	if err != nil {
+		return nil, err
+	}
+	blockDevices, err := blockDeviceMappings(options.AdditionalDisks)
+	if err != nil {
+		return nil, err
+	}
+
 	var ud *string
 	if len(userdata) > 0 {
 		tud := base64.StdEncoding.EncodeToString([]byte(userdata))
 		ud = &tud
 	}
 
-	err := a.ensureInstanceProfile(a.opts.IAMInstanceProfile)
+	err = a.ensureInstanceProfile(a.opts.IAMInstanceProfile)
 	if err != nil {
 		return nil, fmt.Errorf("error verifying IAM instance profile: %v", err)
 	}
@@ -83,14 +93,15 @@ func (a *API) CreateInstances(name, keyname, userdata string, count uint64) ([]*
 
 	for _, subnetId := range subnetIds {
 		inst := ec2.RunInstancesInput{
-			ImageId:          &a.opts.AMI,
-			MinCount:         &cnt,
-			MaxCount:         &cnt,
-			KeyName:          key,
-			InstanceType:     &a.opts.InstanceType,
-			SecurityGroupIds: []*string{&sgId},
-			SubnetId:         &subnetId,
-			UserData:         ud,
+			ImageId:             &a.opts.AMI,
+			MinCount:            &cnt,
+			MaxCount:            &cnt,
+			KeyName:             key,
+			InstanceType:        &instanceType,
+			SecurityGroupIds:    []*string{&sgId},
+			SubnetId:            &subnetId,
+			UserData:            ud,
+			BlockDeviceMappings: blockDevices,
 			IamInstanceProfile: &ec2.IamInstanceProfileSpecification{
 				Name: &a.opts.IAMInstanceProfile,
 			},
@@ -0,0 +1,140 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/coreos/mantle/platform"
+)
+
+// The vendored SDK predates DescribeInstanceTypes, so the request is built
+// here with the query protocol of the EC2 client.
+const opDescribeInstanceTypes = "DescribeInstanceTypes"
+
+type describeInstanceTypesInput struct {
+	_ struct{} `type:"structure"`
+
+	Filters   []*ec2.Filter `locationName:"Filter" locationNameList:"Filter" type:"list"`
+	NextToken *string       `type:"string"`
+}
+
+type describeInstanceTypesOutput struct {
+	_ struct{} `type:"structure"`
+
+	InstanceTypes []*instanceTypeInfo `locationName:"instanceTypeSet" locationNameList:"item" type:"list"`
+	NextToken     *string             `locationName:"nextToken" type:"string"`
+}
+
+type instanceTypeInfo struct {
+	_ struct{} `type:"structure"`
+
+	InstanceType *string     `locationName:"instanceType" type:"string"`
+	MemoryInfo   *memoryInfo `locationName:"memoryInfo" type:"structure"`
+	VCpuInfo     *vCpuInfo   `locationName:"vCpuInfo" type:"structure"`
+}
+
+type memoryInfo struct {
+	_ struct{} `type:"structure"`
+
+	SizeInMiB *int64 `locationName:"sizeInMiB" type:"long"`
+}
+
+type vCpuInfo struct {
+	_ struct{} `type:"structure"`
+
+	DefaultVCpus *int64 `locationName:"defaultVCpus" type:"integer"`
+}
+
+// describeInstanceTypes returns the instance types of the given family in
+// the region of the API.
+func (a *API) describeInstanceTypes(family string) ([]platform.MachineType, error) {
+	input := &describeInstanceTypesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-type"),
+				Values: []*string{aws.String(family + ".*")},
+			},
+		},
+	}
+	var types []platform.MachineType
+	for {
+		output := &describeInstanceTypesOutput{}
+		req := a.ec2.NewRequest(&request.Operation{
+			Name:       opDescribeInstanceTypes,
+			HTTPMethod: "POST",
+			HTTPPath:   "/",
+		}, input, output)
+		if err := req.Send(); err != nil {
+			return nil, fmt.Errorf("describing instance types of family %v: %v", family, err)
+		}
+		for _, t := range output.InstanceTypes {
+			if t.InstanceType == nil || t.MemoryInfo == nil || t.VCpuInfo == nil {
+				continue
+			}
+			types = append(types, platform.MachineType{
+				Name:   aws.StringValue(t.InstanceType),
+				Memory: int(aws.Int64Value(t.MemoryInfo.SizeInMiB)),
+				CPUs:   int(aws.Int64Value(t.VCpuInfo.DefaultVCpus)),
+			})
+		}
+		if aws.StringValue(output.NextToken) == "" {
+			return types, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// selectInstanceType returns the default instance type, or the smallest
+// instance type of the same family meeting the memory and CPU requirements
+// of options.
+func (a *API) selectInstanceType(options platform.MachineOptions) (string, error) {
+	def := a.opts.InstanceType
+	if !options.HasSizeRequirements() {
+		return def, nil
+	}
+	types, err := a.describeInstanceTypes(strings.SplitN(def, ".", 2)[0])
+	if err != nil {
+		return "", err
+	}
+	return platform.SelectMachineType(def, types, options)
+}
+
+// blockDeviceMappings returns the mappings of EBS volumes for disks, which
+// are deleted with the instance.
+func blockDeviceMappings(disks []platform.Disk) ([]*ec2.BlockDeviceMapping, error) {
+	var mappings []*ec2.BlockDeviceMapping
+	for i, disk := range disks {
+		size, err := disk.SizeInGiB()
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, &ec2.BlockDeviceMapping{
+			// xvda is the root device
+			DeviceName: aws.String(fmt.Sprintf("/dev/xvd%c", 'b'+i)),
+			Ebs: &ec2.EbsBlockDevice{
+				DeleteOnTermination: aws.Bool(true),
+				VolumeSize:          aws.Int64(size),
+				VolumeType:          aws.String(ec2.VolumeTypeGp2),
+			},
+		})
+	}
+	return mappings, nil
+}
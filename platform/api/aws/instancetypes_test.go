@@ -0,0 +1,105 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/coreos/mantle/platform"
+)
+
+const instanceTypeItem = `<item><instanceType>%s</instanceType><memoryInfo><sizeInMiB>%d</sizeInMiB></memoryInfo><vCpuInfo><defaultVCpus>%d</defaultVCpus></vCpuInfo></item>`
+
+// describeInstanceTypesServer answers DescribeInstanceTypes with m5.large
+// on the first page and m5.xlarge on the second one.
+func describeInstanceTypesServer(t *testing.T, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parsing request: %v", err)
+		}
+		if action := r.Form.Get("Action"); action != "DescribeInstanceTypes" {
+			t.Errorf("unexpected action %q", action)
+		}
+		if name, value := r.Form.Get("Filter.1.Name"), r.Form.Get("Filter.1.Value.1"); name != "instance-type" || value != "m5.*" {
+			t.Errorf("unexpected filter %v=%v", name, value)
+		}
+		var item, next string
+		switch token := r.Form.Get("NextToken"); token {
+		case "":
+			item = fmt.Sprintf(instanceTypeItem, "m5.large", 8192, 2)
+			next = "<nextToken>page2</nextToken>"
+		case "page2":
+			item = fmt.Sprintf(instanceTypeItem, "m5.xlarge", 16384, 4)
+		default:
+			t.Errorf("unexpected NextToken %q", token)
+		}
+		fmt.Fprintf(w, `<DescribeInstanceTypesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>1</requestId><instanceTypeSet>%s</instanceTypeSet>%s</DescribeInstanceTypesResponse>`, item, next)
+	}))
+}
+
+func TestSelectInstanceType(t *testing.T) {
+	var requests int
+	srv := describeInstanceTypesServer(t, &requests)
+	defer srv.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		def      string
+		options  platform.MachineOptions
+		want     string
+		requests int
+	}{
+		{"m5.large", platform.MachineOptions{}, "m5.large", 0},
+		{"m5.large", platform.MachineOptions{MinMemory: 8192}, "m5.large", 2},
+		// only on the second page
+		{"m5.large", platform.MachineOptions{MinMemory: 10000}, "m5.xlarge", 2},
+		// not listed, kept with a warning
+		{"m5.metal", platform.MachineOptions{MinCPUs: 4}, "m5.metal", 2},
+	} {
+		requests = 0
+		a := &API{ec2: ec2.New(sess), opts: &Options{InstanceType: tt.def}}
+		got, err := a.selectInstanceType(tt.options)
+		if err != nil {
+			t.Errorf("%v with %+v: %v", tt.def, tt.options, err)
+		} else if got != tt.want {
+			t.Errorf("%v with %+v: expected %v, got %v", tt.def, tt.options, tt.want, got)
+		}
+		if requests != tt.requests {
+			t.Errorf("%v with %+v: expected %d requests, got %d", tt.def, tt.options, tt.requests, requests)
+		}
+	}
+
+	a := &API{ec2: ec2.New(sess), opts: &Options{InstanceType: "m5.large"}}
+	if _, err := a.selectInstanceType(platform.MachineOptions{MinCPUs: 16}); err == nil {
+		t.Errorf("expected an error when no size has enough CPUs")
+	}
+}
@@ -44,6 +44,7 @@ type API struct {
 	rgClient   resources.GroupsClient
 	imgClient  compute.ImagesClient
 	compClient compute.VirtualMachinesClient
+	sizeClient compute.VirtualMachineSizesClient
 	netClient  network.VirtualNetworksClient
 	subClient  network.SubnetsClient
 	ipClient   network.PublicIPAddressesClient
@@ -142,6 +143,8 @@ func (a *API) SetupClients() error {
 	a.imgClient.Authorizer = auther
 	a.compClient = compute.NewVirtualMachinesClientWithBaseURI(auther.BaseURI, auther.SubscriptionID)
 	a.compClient.Authorizer = auther
+	a.sizeClient = compute.NewVirtualMachineSizesClientWithBaseURI(auther.BaseURI, auther.SubscriptionID)
+	a.sizeClient.Authorizer = auther
 
 	auther, err = auth.GetClientSetup(network.DefaultBaseURI)
 	if err != nil {
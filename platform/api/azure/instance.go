@@ -25,9 +25,13 @@ import (
 	"github.com/Azure/azure-sdk-for-go/arm/compute"
 	"github.com/Azure/azure-sdk-for-go/arm/network"
 
+	"github.com/coreos/mantle/platform"
 	"github.com/coreos/mantle/util"
 )
 
+// firstNumber finds the number of vCPUs in the name of a VM size.
+var firstNumber = regexp.MustCompile(`[0-9]+`)
+
 type Machine struct {
 	ID               string
 	PublicIPAddress  string
@@ -36,7 +40,7 @@ type Machine struct {
 	PublicIPName     string
 }
 
-func (a *API) getVMParameters(name, userdata, sshkey, storageAccountURI string, ip *network.PublicIPAddress, nic *network.Interface) compute.VirtualMachine {
+func (a *API) getVMParameters(name, userdata, sshkey, storageAccountURI, size string, ip *network.PublicIPAddress, nic *network.Interface) compute.VirtualMachine {
 	osProfile := compute.OSProfile{
 		AdminUsername: util.StrToPtr("core"),
 		ComputerName:  &name,
@@ -76,7 +80,7 @@ func (a *API) getVMParameters(name, userdata, sshkey, storageAccountURI string,
 		},
 		VirtualMachineProperties: &compute.VirtualMachineProperties{
 			HardwareProfile: &compute.HardwareProfile{
-				VMSize: compute.VirtualMachineSizeTypes(a.opts.Size),
+				VMSize: compute.VirtualMachineSizeTypes(size),
 			},
 			StorageProfile: &compute.StorageProfile{
 				ImageReference: imgRef,
@@ -105,7 +109,49 @@ func (a *API) getVMParameters(name, userdata, sshkey, storageAccountURI string,
 	}
 }
 
-func (a *API) CreateInstance(name, userdata, sshkey, resourceGroup, storageAccount string) (*Machine, error) {
+// sizeKind returns the name of a VM size without its number of vCPUs, e.g.
+// Standard_Ds_v3 for Standard_D4s_v3.
+func sizeKind(size string) string {
+	if loc := firstNumber.FindStringIndex(size); loc != nil {
+		return size[:loc[0]] + size[loc[1]:]
+	}
+	return size
+}
+
+// vmSize returns the configured VM size, or the smallest one of the same
+// kind meeting the memory and CPU requirements of options.
+func (a *API) vmSize(options platform.MachineOptions) (string, error) {
+	if !options.HasSizeRequirements() {
+		return a.opts.Size, nil
+	}
+	list, err := a.sizeClient.List(a.opts.Location)
+	if err != nil {
+		return "", fmt.Errorf("listing VM sizes: %v", err)
+	}
+	var sizes []platform.MachineType
+	if list.Value != nil {
+		for _, s := range *list.Value {
+			if s.Name == nil || s.MemoryInMB == nil || s.NumberOfCores == nil || sizeKind(*s.Name) != sizeKind(a.opts.Size) {
+				continue
+			}
+			sizes = append(sizes, platform.MachineType{
+				Name:   *s.Name,
+				Memory: int(*s.MemoryInMB),
+				CPUs:   int(*s.NumberOfCores),
+			})
+		}
+	}
+	return platform.SelectMachineType(a.opts.Size, sizes, options)
+}
+
+// CreateInstance creates a VM of a size meeting the memory and CPU
+// requirements of options.
+func (a *API) CreateInstance(name, userdata, sshkey, resourceGroup, storageAccount string, options platform.MachineOptions) (*Machine, error) {
+	size, err := a.vmSize(options)
+	if err != nil {
+		return nil, err
+	}
+
 	subnet, err := a.getSubnet(resourceGroup)
 	if err != nil {
 		return nil, fmt.Errorf("preparing network resources: %v", err)
@@ -127,7 +173,7 @@ func (a *API) CreateInstance(name, userdata, sshkey, resourceGroup, storageAccou
 		return nil, fmt.Errorf("couldn't get NIC name")
 	}
 
-	vmParams := a.getVMParameters(name, userdata, sshkey, fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccount), ip, nic)
+	vmParams := a.getVMParameters(name, userdata, sshkey, fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccount), size, ip, nic)
 
 	_, err = a.compClient.CreateOrUpdate(resourceGroup, name, vmParams, nil)
 	if err != nil {
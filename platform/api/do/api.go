@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coreos/pkg/capnslog"
@@ -151,16 +152,65 @@ func (a *API) PreflightCheck(ctx context.Context) error {
 	return nil
 }
 
-func (a *API) CreateDroplet(ctx context.Context, name string, sshKeyID int, userdata string) (*godo.Droplet, error) {
+// sizeSlug returns the configured size, or the smallest one of the same
+// kind available in the region meeting the memory and CPU requirements of
+// options. The kind is the first part of the slug, e.g. s for s-1vcpu-2gb.
+func (a *API) sizeSlug(ctx context.Context, options platform.MachineOptions) (string, error) {
+	if !options.HasSizeRequirements() {
+		return a.opts.Size, nil
+	}
+	kind := strings.SplitN(a.opts.Size, "-", 2)[0] + "-"
+	page := godo.ListOptions{
+		Page:    1,
+		PerPage: 200,
+	}
+	var sizes []platform.MachineType
+	for {
+		list, _, err := a.c.Sizes.List(ctx, &page)
+		if err != nil {
+			return "", fmt.Errorf("listing sizes: %v", err)
+		}
+		for _, size := range list {
+			if size.Available && strings.HasPrefix(size.Slug, kind) && hasRegion(size.Regions, a.opts.Region) {
+				sizes = append(sizes, platform.MachineType{
+					Name:   size.Slug,
+					Memory: size.Memory,
+					CPUs:   size.Vcpus,
+				})
+			}
+		}
+		if len(list) < page.PerPage {
+			break
+		}
+		page.Page += 1
+	}
+	return platform.SelectMachineType(a.opts.Size, sizes, options)
+}
+
+func hasRegion(regions []string, region string) bool {
+	for _, r := range regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateDroplet creates a droplet of a size meeting the memory and CPU
+// requirements of options.
+func (a *API) CreateDroplet(ctx context.Context, name string, sshKeyID int, userdata string, options platform.MachineOptions) (*godo.Droplet, error) {
 	var droplet *godo.Droplet
-	var err error
+	size, err := a.sizeSlug(ctx, options)
+	if err != nil {
+		return nil, err
+	}
 	// DO frequently gives us 422 errors saying "Please try again". Retry every 10 seconds
 	// for up to 5 min
 	err = util.RetryConditional(5*6, 10*time.Second, shouldRetry, func() error {
 		droplet, _, err = a.c.Droplets.Create(ctx, &godo.DropletCreateRequest{
 			Name:              name,
 			Region:            a.opts.Region,
-			Size:              a.opts.Size,
+			Size:              size,
 			Image:             a.image,
 			SSHKeys:           []godo.DropletCreateSSHKey{{ID: sshKeyID}},
 			IPv6:              false,
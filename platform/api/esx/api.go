@@ -346,7 +346,9 @@ func (a *API) CleanupDevice(name string) error {
 	return nil
 }
 
-func (a *API) CreateDevice(name string, conf *conf.Conf, ips *IpPair) (*ESXMachine, error) {
+// CreateDevice creates a VM with at least 2 GiB of memory, meeting the
+// requirements of options.
+func (a *API) CreateDevice(name string, conf *conf.Conf, ips *IpPair, options platform.MachineOptions) (*ESXMachine, error) {
 	if a.options.BaseVMName == "" && a.options.OvaPath == "" {
 		return nil, fmt.Errorf("Base VM Name or VM image path must be supplied")
 	}
@@ -497,10 +499,21 @@ func (a *API) CreateDevice(name string, conf *conf.Conf, ips *IpPair) (*ESXMachi
 		// End of hack
 	}
 
-	plog.Debugf("Setting memory to 2 GiB")
-	err = a.setMemoryMB(vm, 2048)
+	memoryMB := int64(2048)
+	if int64(options.MinMemory) > memoryMB {
+		memoryMB = int64(options.MinMemory)
+	}
+	plog.Debugf("Setting memory to %d MiB", memoryMB)
+	err = a.setHardware(vm, memoryMB, int32(options.MinCPUs))
 	if err != nil {
-		return nil, fmt.Errorf("setting memory: %v", err)
+		return nil, fmt.Errorf("setting memory and CPUs: %v", err)
+	}
+
+	for _, disk := range options.AdditionalDisks {
+		err = a.addDisk(vm, defaults.datastore, disk)
+		if err != nil {
+			return nil, fmt.Errorf("adding disk: %v", err)
+		}
 	}
 
 	plog.Debugf("Adding serial port for VM")
@@ -741,11 +754,22 @@ func networkMap(finder *find.Finder, e *ovf.Envelope) (p []types.OvfNetworkMappi
 	return
 }
 
-func (a *API) setMemoryMB(vm *object.VirtualMachine, memoryMB int64) error {
-	task, err := vm.Reconfigure(a.ctx, types.VirtualMachineConfigSpec{
-		MemoryMB: memoryMB,
-	})
+// setHardware sets the memory of vm and raises its number of CPUs to at
+// least minCPUs.
+func (a *API) setHardware(vm *object.VirtualMachine, memoryMB int64, minCPUs int32) error {
+	var mvm mo.VirtualMachine
+	err := vm.Properties(a.ctx, vm.Reference(), []string{"config.hardware"}, &mvm)
+	if err != nil {
+		return err
+	}
 
+	spec := types.VirtualMachineConfigSpec{
+		MemoryMB: memoryMB,
+	}
+	if minCPUs > mvm.Config.Hardware.NumCPU {
+		spec.NumCPUs = minCPUs
+	}
+	task, err := vm.Reconfigure(a.ctx, spec)
 	if err != nil {
 		return err
 	}
@@ -753,6 +777,28 @@ func (a *API) setMemoryMB(vm *object.VirtualMachine, memoryMB int64) error {
 	return task.Wait(a.ctx)
 }
 
+// addDisk adds an empty thin provisioned disk to vm, which is deleted with
+// it.
+func (a *API) addDisk(vm *object.VirtualMachine, datastore *object.Datastore, disk platform.Disk) error {
+	size, err := disk.SizeInGiB()
+	if err != nil {
+		return err
+	}
+
+	devices, err := vm.Device(a.ctx)
+	if err != nil {
+		return fmt.Errorf("getting devices: %v", err)
+	}
+	controller, err := devices.FindDiskController("")
+	if err != nil {
+		return err
+	}
+	vdisk := devices.CreateDisk(controller, datastore.Reference(), "")
+	vdisk.CapacityInKB = size * 1024 * 1024
+
+	return vm.AddDevice(a.ctx, vdisk)
+}
+
 func (a *API) updateGuestVariable(vm *object.VirtualMachine, key, value string) error {
 	config := []types.BaseOptionValue{
 		&types.OptionValue{
@@ -21,7 +21,10 @@ import (
 	"time"
 
 	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/net/context"
 	"google.golang.org/api/compute/v1"
+
+	"github.com/coreos/mantle/platform"
 )
 
 func (a *API) vmname() string {
@@ -31,7 +34,7 @@ func (a *API) vmname() string {
 }
 
 // Taken from: https://github.com/golang/build/blob/master/buildlet/gce.go
-func (a *API) mkinstance(userdata, name string, keys []*agent.Key) *compute.Instance {
+func (a *API) mkinstance(userdata, name, machineType string, keys []*agent.Key, disks []*compute.AttachedDisk) *compute.Instance {
 	mantle := "mantle"
 	metadataItems := []*compute.MetadataItems{
 		&compute.MetadataItems{
@@ -57,7 +60,7 @@ func (a *API) mkinstance(userdata, name string, keys []*agent.Key) *compute.Inst
 
 	instance := &compute.Instance{
 		Name:        name,
-		MachineType: instancePrefix + "/zones/" + a.options.Zone + "/machineTypes/" + a.options.MachineType,
+		MachineType: instancePrefix + "/zones/" + a.options.Zone + "/machineTypes/" + machineType,
 		Metadata: &compute.Metadata{
 			Items: metadataItems,
 		},
@@ -92,6 +95,7 @@ func (a *API) mkinstance(userdata, name string, keys []*agent.Key) *compute.Inst
 			},
 		},
 	}
+	instance.Disks = append(instance.Disks, disks...)
 	// add cloud config
 	if userdata != "" {
 		instance.Metadata.Items = append(instance.Metadata.Items, &compute.MetadataItems{
@@ -104,10 +108,69 @@ func (a *API) mkinstance(userdata, name string, keys []*agent.Key) *compute.Inst
 
 }
 
-// CreateInstance creates a Google Compute Engine instance.
-func (a *API) CreateInstance(userdata string, keys []*agent.Key) (*compute.Instance, error) {
+// machineType returns the configured machine type, or the smallest one of
+// the same kind, e.g. n1-standard, meeting the memory and CPU requirements
+// of options.
+func (a *API) machineType(options platform.MachineOptions) (string, error) {
+	if !options.HasSizeRequirements() {
+		return a.options.MachineType, nil
+	}
+	kind := a.options.MachineType[:strings.LastIndex(a.options.MachineType, "-")+1]
+	var types []platform.MachineType
+	err := a.compute.MachineTypes.List(a.options.Project, a.options.Zone).Pages(context.TODO(), func(l *compute.MachineTypeList) error {
+		for _, t := range l.Items {
+			if strings.HasPrefix(t.Name, kind) && t.Deprecated == nil {
+				types = append(types, platform.MachineType{
+					Name:   t.Name,
+					Memory: int(t.MemoryMb),
+					CPUs:   int(t.GuestCpus),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("listing machine types: %v", err)
+	}
+	return platform.SelectMachineType(a.options.MachineType, types, options)
+}
+
+// attachedDisks returns the empty persistent disks to create for the
+// additional disks of an instance, which are deleted with it.
+func (a *API) attachedDisks(name string, disks []platform.Disk) ([]*compute.AttachedDisk, error) {
+	var attached []*compute.AttachedDisk
+	for i, disk := range disks {
+		size, err := disk.SizeInGiB()
+		if err != nil {
+			return nil, err
+		}
+		attached = append(attached, &compute.AttachedDisk{
+			AutoDelete: true,
+			Type:       "PERSISTENT",
+			InitializeParams: &compute.AttachedDiskInitializeParams{
+				DiskName:   fmt.Sprintf("%s-%d", name, i+1),
+				DiskType:   "/zones/" + a.options.Zone + "/diskTypes/" + a.options.DiskType,
+				DiskSizeGb: size,
+			},
+		})
+	}
+	return attached, nil
+}
+
+// CreateInstance creates a Google Compute Engine instance. Its machine type
+// is picked to meet the memory and CPU requirements of options, and the
+// additional disks of options are attached to it.
+func (a *API) CreateInstance(userdata string, keys []*agent.Key, options platform.MachineOptions) (*compute.Instance, error) {
 	name := a.vmname()
-	inst := a.mkinstance(userdata, name, keys)
+	machineType, err := a.machineType(options)
+	if err != nil {
+		return nil, err
+	}
+	disks, err := a.attachedDisks(name, options.AdditionalDisks)
+	if err != nil {
+		return nil, err
+	}
+	inst := a.mkinstance(userdata, name, machineType, keys, disks)
 
 	plog.Debugf("Creating instance %q", name)
 
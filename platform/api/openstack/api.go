@@ -217,6 +217,35 @@ func (a *API) resolveFlavor() (string, error) {
 	return "", fmt.Errorf("specified flavor %q not found", a.opts.Flavor)
 }
 
+// flavorFor returns the configured flavor, or the smallest one meeting the
+// memory and CPU requirements of options.
+func (a *API) flavorFor(options platform.MachineOptions) (string, error) {
+	if !options.HasSizeRequirements() {
+		return a.opts.Flavor, nil
+	}
+	pager := flavors.ListDetail(a.computeClient, flavors.ListOpts{})
+
+	pages, err := unwrapPages(pager, false)
+	if err != nil {
+		return "", fmt.Errorf("flavors: %v", err)
+	}
+
+	flavors, err := flavors.ExtractFlavors(pages)
+	if err != nil {
+		return "", fmt.Errorf("extracting flavors: %v", err)
+	}
+
+	var types []platform.MachineType
+	for _, flavor := range flavors {
+		types = append(types, platform.MachineType{
+			Name:   flavor.ID,
+			Memory: flavor.RAM,
+			CPUs:   flavor.VCPUs,
+		})
+	}
+	return platform.SelectMachineType(a.opts.Flavor, types, options)
+}
+
 func (a *API) ResolveImage(img string) (string, error) {
 	pager := computeImages.ListDetail(a.computeClient, computeImages.ListOpts{})
 
@@ -261,7 +290,14 @@ func (a *API) PreflightCheck() error {
 	return nil
 }
 
-func (a *API) CreateServer(name, sshKeyID, userdata string) (*Server, error) {
+// CreateServer creates a server of a flavor meeting the memory and CPU
+// requirements of options.
+func (a *API) CreateServer(name, sshKeyID, userdata string, options platform.MachineOptions) (*Server, error) {
+	flavor, err := a.flavorFor(options)
+	if err != nil {
+		return nil, err
+	}
+
 	networkID := a.opts.Network
 	if networkID == "" {
 		networks, err := a.getNetworks()
@@ -279,7 +315,7 @@ func (a *API) CreateServer(name, sshKeyID, userdata string) (*Server, error) {
 	server, err := servers.Create(a.computeClient, keypairs.CreateOptsExt{
 		CreateOptsBuilder: servers.CreateOpts{
 			Name:      name,
-			FlavorRef: a.opts.Flavor,
+			FlavorRef: flavor,
 			ImageRef:  a.opts.Image,
 			Metadata: map[string]string{
 				"CreatedBy": "mantle",
@@ -66,6 +66,23 @@ var (
 		"amd64-usr": "baremetal_0",
 		"arm64-usr": "c2.large.arm",
 	}
+	// plans are the plans a bigger plan than the configured one is
+	// picked from, counting hardware threads as CPUs
+	plans = map[string][]platform.MachineType{
+		"amd64-usr": {
+			{Name: "baremetal_0", Memory: 8192, CPUs: 4},
+			{Name: "c3.small.x86", Memory: 32768, CPUs: 16},
+			{Name: "baremetal_1", Memory: 32768, CPUs: 8},
+			{Name: "c3.medium.x86", Memory: 65536, CPUs: 48},
+			{Name: "baremetal_3", Memory: 131072, CPUs: 32},
+			{Name: "baremetal_2", Memory: 262144, CPUs: 48},
+		},
+		"arm64-usr": {
+			{Name: "c2.large.arm", Memory: 131072, CPUs: 32},
+			{Name: "baremetal_2a", Memory: 131072, CPUs: 96},
+			{Name: "c3.large.arm", Memory: 262144, CPUs: 80},
+		},
+	}
 	linuxConsole = map[string]string{
 		"amd64-usr": "ttyS1,115200n8",
 		"arm64-usr": "ttyAMA0,115200n8",
@@ -184,7 +201,9 @@ func (a *API) PreflightCheck() error {
 }
 
 // console is optional, and is closed on error or when the device is deleted.
-func (a *API) CreateDevice(hostname string, conf *conf.Conf, console Console) (*packngo.Device, error) {
+// The plan of the device is picked to meet the memory and CPU requirements
+// of options.
+func (a *API) CreateDevice(hostname string, conf *conf.Conf, console Console, options platform.MachineOptions) (*packngo.Device, error) {
 	consoleStarted := false
 	defer func() {
 		if console != nil && !consoleStarted {
@@ -192,6 +211,11 @@ func (a *API) CreateDevice(hostname string, conf *conf.Conf, console Console) (*
 		}
 	}()
 
+	plan, err := platform.SelectMachineType(a.opts.Plan, plans[a.opts.Board], options)
+	if err != nil {
+		return nil, err
+	}
+
 	userdata, err := a.wrapUserData(conf)
 	if err != nil {
 		return nil, err
@@ -212,7 +236,7 @@ func (a *API) CreateDevice(hostname string, conf *conf.Conf, console Console) (*
 	}
 	defer a.bucket.Delete(context.TODO(), ipxeScriptName)
 
-	device, err := a.createDevice(hostname, ipxeScriptURL)
+	device, err := a.createDevice(hostname, plan, ipxeScriptURL)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't create device: %v", err)
 	}
@@ -478,13 +502,13 @@ boot`, a.opts.InstallerImageKernelURL, userdataURL, linuxConsole[a.opts.Board],
 }
 
 // device creation seems a bit flaky, so try a few times
-func (a *API) createDevice(hostname, ipxeScriptURL string) (device *packngo.Device, err error) {
+func (a *API) createDevice(hostname, plan, ipxeScriptURL string) (device *packngo.Device, err error) {
 	for tries := apiRetries; tries >= 0; tries-- {
 		var response *packngo.Response
 		device, response, err = a.c.Devices.Create(&packngo.DeviceCreateRequest{
 			ProjectID:     a.opts.Project,
 			Facility:      []string{a.opts.Facility},
-			Plan:          a.opts.Plan,
+			Plan:          plan,
 			BillingCycle:  "hourly",
 			Hostname:      hostname,
 			OS:            "custom_ipxe",
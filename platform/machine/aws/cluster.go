@@ -28,6 +28,14 @@ type cluster struct {
 }
 
 func (ac *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return ac.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (ac *cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	if err := options.Check(ac.flight.Features()); err != nil {
+		return nil, err
+	}
+
 	conf, err := ac.RenderUserData(userdata, map[string]string{
 		"$public_ipv4":  "${COREOS_EC2_IPV4_PUBLIC}",
 		"$private_ipv4": "${COREOS_EC2_IPV4_LOCAL}",
@@ -40,7 +48,7 @@ func (ac *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 	if !ac.RuntimeConf().NoSSHKeyInMetadata {
 		keyname = ac.flight.Name()
 	}
-	instances, err := ac.flight.api.CreateInstances(ac.Name(), keyname, conf.String(), 1)
+	instances, err := ac.flight.api.CreateInstances(ac.Name(), keyname, conf.String(), 1, options)
 	if err != nil {
 		return nil, err
 	}
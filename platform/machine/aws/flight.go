@@ -73,6 +73,11 @@ func NewFlight(opts *aws.Options) (platform.Flight, error) {
 	return af, nil
 }
 
+// Features returns the features of EC2 machines.
+func (af *flight) Features() []platform.Feature {
+	return []platform.Feature{platform.FeaturePrivateIP, platform.FeatureMultipleDisks, platform.FeatureReboot, platform.FeatureConsoleOutput}
+}
+
 // NewCluster creates an instance of a Cluster suitable for spawning
 // instances on Amazon Web Services' Elastic Compute platform.
 func (af *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
@@ -136,6 +136,11 @@ func NewFlight(opts *azure.Options) (platform.Flight, error) {
 	return af, nil
 }
 
+// Features returns the features of Azure machines.
+func (af *flight) Features() []platform.Feature {
+	return []platform.Feature{platform.FeaturePrivateIP, platform.FeatureReboot, platform.FeatureConsoleOutput}
+}
+
 // NewCluster creates an instance of a Cluster suitable for spawning
 // instances on the Azure platform. The cluster is created in the Flight's
 // Resource Group if it has one. Otherwise the cluster is created in a new
@@ -53,6 +53,10 @@ func (am *machine) ResourceGroup() string {
 	return am.cluster.ResourceGroup
 }
 
+func (am *machine) StorageAccount() string {
+	return am.cluster.StorageAccount
+}
+
 func (am *machine) InterfaceName() string {
 	return am.mach.InterfaceName
 }
@@ -32,6 +32,14 @@ type cluster struct {
 }
 
 func (dc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return dc.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (dc *cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	if err := options.Check(dc.flight.Features()); err != nil {
+		return nil, err
+	}
+
 	conf, err := dc.RenderUserData(userdata, map[string]string{
 		"$public_ipv4":  "${COREOS_DIGITALOCEAN_IPV4_PUBLIC_0}",
 		"$private_ipv4": "${COREOS_DIGITALOCEAN_IPV4_PRIVATE_0}",
@@ -40,7 +48,7 @@ func (dc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 		return nil, err
 	}
 
-	droplet, err := dc.flight.api.CreateDroplet(context.TODO(), dc.vmname(), dc.sshKeyID, conf.String())
+	droplet, err := dc.flight.api.CreateDroplet(context.TODO(), dc.vmname(), dc.sshKeyID, conf.String(), options)
 	if err != nil {
 		return nil, err
 	}
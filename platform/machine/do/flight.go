@@ -83,6 +83,12 @@ func NewFlight(opts *do.Options) (platform.Flight, error) {
 	return df, nil
 }
 
+// Features returns the features of DigitalOcean machines.
+// DigitalOcean provides no API for retrieving console output.
+func (df *flight) Features() []platform.Feature {
+	return []platform.Feature{platform.FeaturePrivateIP, platform.FeatureReboot}
+}
+
 func (df *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
 	bc, err := platform.NewBaseCluster(df.BaseFlight, rconf)
 	if err != nil {
@@ -37,6 +37,14 @@ func (ec *cluster) vmname() string {
 }
 
 func (ec *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return ec.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (ec *cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	if err := options.Check(ec.flight.Features()); err != nil {
+		return nil, err
+	}
+
 	conf, err := ec.RenderUserData(userdata, map[string]string{
 		"$public_ipv4":  "${COREOS_CUSTOM_PUBLIC_IPV4}",
 		"$private_ipv4": "${COREOS_CUSTOM_PRIVATE_IPV4}",
@@ -96,7 +104,7 @@ ExecStart=/usr/bin/bash -c 'echo "COREOS_CUSTOM_PRIVATE_IPV4=$(ip addr show ens1
 ExecStartPost=/usr/bin/ln -fs /run/metadata/flatcar /run/metadata/coreos
 `, false)
 
-	instance, err := ec.flight.api.CreateDevice(ec.vmname(), conf, ipPairMaybe)
+	instance, err := ec.flight.api.CreateDevice(ec.vmname(), conf, ipPairMaybe, options)
 	if err != nil {
 		if ipPairMaybe != nil {
 			plog.Debugf("Setting static IP addresses %v and %v as available", (*ipPairMaybe).Public, (*ipPairMaybe).Private)
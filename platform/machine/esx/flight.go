@@ -89,6 +89,11 @@ func NewFlight(opts *esx.Options) (platform.Flight, error) {
 	return ef, nil
 }
 
+// Features returns the features of ESX machines.
+func (ef *flight) Features() []platform.Feature {
+	return []platform.Feature{platform.FeaturePrivateIP, platform.FeatureMultipleDisks, platform.FeatureReboot, platform.FeatureConsoleOutput}
+}
+
 // NewCluster creates an instance of a Cluster suitable for spawning
 // instances on VMware ESXi vSphere platform.
 func (ef *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
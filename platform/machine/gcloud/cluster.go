@@ -33,6 +33,14 @@ type cluster struct {
 
 // Calling in parallel is ok
 func (gc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return gc.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (gc *cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	if err := options.Check(gc.flight.Features()); err != nil {
+		return nil, err
+	}
+
 	conf, err := gc.RenderUserData(userdata, map[string]string{
 		"$public_ipv4":  "${COREOS_GCE_IP_EXTERNAL_0}",
 		"$private_ipv4": "${COREOS_GCE_IP_LOCAL_0}",
@@ -49,7 +57,7 @@ func (gc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 		}
 	}
 
-	instance, err := gc.flight.api.CreateInstance(conf.String(), keys)
+	instance, err := gc.flight.api.CreateInstance(conf.String(), keys, options)
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +56,11 @@ func NewFlight(opts *gcloud.Options) (platform.Flight, error) {
 	return gf, nil
 }
 
+// Features returns the features of GCE machines.
+func (gf *flight) Features() []platform.Feature {
+	return []platform.Feature{platform.FeaturePrivateIP, platform.FeatureMultipleDisks, platform.FeatureReboot, platform.FeatureConsoleOutput}
+}
+
 func (gf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
 	bc, err := platform.NewBaseCluster(gf.BaseFlight, rconf)
 	if err != nil {
@@ -30,6 +30,14 @@ type cluster struct {
 }
 
 func (oc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return oc.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (oc *cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	if err := options.Check(oc.flight.Features()); err != nil {
+		return nil, err
+	}
+
 	conf, err := oc.RenderUserData(userdata, map[string]string{
 		"$public_ipv4":  "${COREOS_OPENSTACK_IPV4_PUBLIC}",
 		"$private_ipv4": "${COREOS_OPENSTACK_IPV4_LOCAL}",
@@ -42,7 +50,7 @@ func (oc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 	if !oc.RuntimeConf().NoSSHKeyInMetadata {
 		keyname = oc.flight.Name()
 	}
-	instance, err := oc.flight.api.CreateServer(oc.vmname(), keyname, conf.String())
+	instance, err := oc.flight.api.CreateServer(oc.vmname(), keyname, conf.String(), options)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +69,11 @@ func NewFlight(opts *openstack.Options) (platform.Flight, error) {
 	return of, nil
 }
 
+// Features returns the features of OpenStack machines.
+func (of *flight) Features() []platform.Feature {
+	return []platform.Feature{platform.FeaturePrivateIP, platform.FeatureReboot, platform.FeatureConsoleOutput}
+}
+
 // NewCluster creates an instance of a Cluster suitable for spawning
 // instances on the OpenStack platform.
 func (of *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
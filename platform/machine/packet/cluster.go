@@ -34,6 +34,14 @@ type cluster struct {
 }
 
 func (pc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error) {
+	return pc.NewMachineWithOptions(userdata, platform.MachineOptions{})
+}
+
+func (pc *cluster) NewMachineWithOptions(userdata *conf.UserData, options platform.MachineOptions) (platform.Machine, error) {
+	if err := options.Check(pc.flight.Features()); err != nil {
+		return nil, err
+	}
+
 	conf, err := pc.RenderUserData(userdata, map[string]string{
 		"$public_ipv4":  "${COREOS_PACKET_IPV4_PUBLIC_0}",
 		"$private_ipv4": "${COREOS_PACKET_IPV4_PRIVATE_0}",
@@ -77,7 +85,7 @@ func (pc *cluster) NewMachine(userdata *conf.UserData) (platform.Machine, error)
 		}
 
 		// CreateDevice unconditionally closes console when done with it
-		device, err = pc.flight.api.CreateDevice(vmname, conf, pcons)
+		device, err = pc.flight.api.CreateDevice(vmname, conf, pcons, options)
 		if err != nil {
 			continue // provisioning error
 		}
@@ -67,6 +67,11 @@ func NewFlight(opts *packet.Options) (platform.Flight, error) {
 	return pf, nil
 }
 
+// Features returns the features of Packet machines.
+func (pf *flight) Features() []platform.Feature {
+	return []platform.Feature{platform.FeaturePrivateIP, platform.FeatureReboot, platform.FeatureConsoleOutput}
+}
+
 func (pf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
 	bc, err := platform.NewBaseCluster(pf.BaseFlight, rconf)
 	if err != nil {
@@ -103,6 +103,12 @@ func NewFlight(opts *Options) (platform.Flight, error) {
 	return qf, nil
 }
 
+// Features returns the features of QEMU machines.
+func (qf *flight) Features() []platform.Feature {
+	// machines share a network bridge
+	return append(platform.QEMUFeatures(qf.opts.Board, qf.opts.BIOSImage), platform.FeaturePrivateIP)
+}
+
 // NewCluster creates a Cluster instance, suitable for running virtual
 // machines in QEMU.
 func (qf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
@@ -102,6 +102,7 @@ func (qc *Cluster) NewMachineWithOptions(userdata *conf.UserData, options platfo
 	qc.mu.Lock()
 
 	qmCmd = append(qmCmd, "-netdev", "user,id=eth0,hostfwd=tcp:127.0.0.1:0-:22", "-device", platform.Virtio(qc.flight.opts.Board, "net", "netdev=eth0"))
+	qmCmd = append(qmCmd, platform.QEMUNicArgs(qc.flight.opts.Board, options)...)
 
 	plog.Debugf("NewMachine: %q", qmCmd)
 
@@ -54,6 +54,12 @@ func NewFlight(opts *qemu.Options) (platform.Flight, error) {
 	return qf, nil
 }
 
+// Features returns the features of QEMU machines.
+func (qf *flight) Features() []platform.Feature {
+	// machines use separate user mode networks
+	return platform.QEMUFeatures(qf.opts.Board, qf.opts.BIOSImage)
+}
+
 // NewCluster creates a Cluster instance, suitable for running virtual
 // machines in QEMU.
 func (qf *flight) NewCluster(rconf *platform.RuntimeConfig) (platform.Cluster, error) {
@@ -45,6 +45,11 @@ func (m *machine) PrivateIP() string {
 	return m.ip
 }
 
+// PID returns the process ID of the qemu process running the machine.
+func (m *machine) PID() int {
+	return m.qemu.Pid()
+}
+
 func (m *machine) RuntimeConf() platform.RuntimeConfig {
 	return m.qc.RuntimeConf()
 }
@@ -43,6 +43,21 @@ var (
 // Name is a unique identifier for a platform.
 type Name string
 
+// Feature is a capability of a platform which tests can require.
+type Feature string
+
+const (
+	FeaturePrivateIP      Feature = "private-ip"       // machines of a cluster can reach each other on their private IPs
+	FeatureMultipleDisks  Feature = "multiple-disks"   // machines can be created with additional disks
+	FeatureDiskDeviceOpts Feature = "disk-device-opts" // the DeviceOpts of additional disks are passed to qemu
+	FeatureMultipleNICs   Feature = "multiple-nics"    // machines can be created with additional network interfaces
+	FeatureReboot         Feature = "reboot"           // machines can be rebooted
+	FeatureConsoleOutput  Feature = "console-output"   // the console output of machines is collected
+	FeatureUEFI           Feature = "uefi"             // machines boot with UEFI
+	FeatureTPM            Feature = "tpm"              // machines have a TPM
+	FeatureIPv6           Feature = "ipv6"             // machines have IPv6 connectivity
+)
+
 // Machine represents a Container Linux instance.
 type Machine interface {
 	// ID returns the plaform-specific machine identifier.
@@ -96,6 +111,10 @@ type Cluster interface {
 	// NewMachine creates a new Container Linux machine.
 	NewMachine(userdata *conf.UserData) (Machine, error)
 
+	// NewMachineWithOptions creates a new Container Linux machine
+	// meeting the requirements of options.
+	NewMachineWithOptions(userdata *conf.UserData, options MachineOptions) (Machine, error)
+
 	// Machines returns a slice of the active machines in the Cluster.
 	Machines() []Machine
 
@@ -134,6 +153,10 @@ type Flight interface {
 	// Clusters returns a slice of the active Clusters.
 	Clusters() []Cluster
 
+	// Features returns the features the machines of the Flight's
+	// clusters support.
+	Features() []Feature
+
 	// Destroy terminates each cluster and frees any other associated
 	// resources.  It should log any failures; since they are not
 	// actionable, it does not return an error.
@@ -282,6 +305,13 @@ func InstallFile(in io.Reader, m Machine, to string) error {
 // NewMachines spawns n instances in cluster c, with
 // each instance passed the same userdata.
 func NewMachines(c Cluster, userdata *conf.UserData, n int) ([]Machine, error) {
+	return NewMachinesWithOptions(c, userdata, n, MachineOptions{})
+}
+
+// NewMachinesWithOptions spawns n instances in cluster c, with each
+// instance passed the same userdata and meeting the requirements of
+// options.
+func NewMachinesWithOptions(c Cluster, userdata *conf.UserData, n int, options MachineOptions) ([]Machine, error) {
 	var wg sync.WaitGroup
 
 	mchan := make(chan Machine, n)
@@ -291,7 +321,7 @@ func NewMachines(c Cluster, userdata *conf.UserData, n int) ([]Machine, error) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			m, err := c.NewMachine(userdata)
+			m, err := c.NewMachineWithOptions(userdata, options)
 			if err != nil {
 				errchan <- err
 			}
@@ -15,13 +15,13 @@
 package platform
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,21 +31,7 @@ import (
 	"github.com/coreos/mantle/util"
 )
 
-type MachineOptions struct {
-	AdditionalDisks []Disk
-}
-
-type Disk struct {
-	Size        string   // disk image size in bytes, optional suffixes "K", "M", "G", "T" allowed. Incompatible with BackingFile
-	BackingFile string   // raw disk image to use. Incompatible with Size.
-	DeviceOpts  []string // extra options to pass to qemu. "serial=XXXX" makes disks show up as /dev/disk/by-id/virtio-<serial>
-}
-
-var (
-	ErrNeedSizeOrFile  = errors.New("Disks need either Size or BackingFile specified")
-	ErrBothSizeAndFile = errors.New("Only one of Size and BackingFile can be specified")
-	primaryDiskOptions = []string{"serial=primary-disk"}
-)
+var primaryDiskOptions = []string{"serial=primary-disk"}
 
 // Copy Container Linux input image and specialize copy for running kola tests.
 // Return FD to the copy, which is a deleted file.
@@ -248,7 +234,7 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 			"qemu-system-x86_64",
 			"-machine", "accel=kvm",
 			"-cpu", "host",
-			"-m", "2512",
+			"-m", memory(2512, options),
 		}
 	case "amd64--arm64-usr":
 		qmBinary = "qemu-system-aarch64"
@@ -256,7 +242,7 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 			"qemu-system-aarch64",
 			"-machine", "virt",
 			"-cpu", "cortex-a57",
-			"-m", "2048",
+			"-m", memory(2048, options),
 		}
 	case "arm64--amd64-usr":
 		qmBinary = "qemu-system-x86_64"
@@ -264,7 +250,7 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 			"qemu-system-x86_64",
 			"-machine", "pc-q35-2.8",
 			"-cpu", "kvm64",
-			"-m", "2512",
+			"-m", memory(2512, options),
 		}
 	case "arm64--arm64-usr":
 		qmBinary = "qemu-system-aarch64"
@@ -272,7 +258,7 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 			"qemu-system-aarch64",
 			"-machine", "virt,accel=kvm,gic-version=3",
 			"-cpu", "host",
-			"-m", "2048",
+			"-m", memory(2048, options),
 		}
 	default:
 		panic("host-guest combo not supported: " + combo)
@@ -280,7 +266,7 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 
 	qmCmd = append(qmCmd,
 		"-bios", biosImage,
-		"-smp", "4",
+		"-smp", strconv.Itoa(maxInt(4, options.MinCPUs)),
 		"-uuid", uuid,
 		"-display", "none",
 		"-chardev", "file,id=log,path="+consolePath,
@@ -348,6 +334,44 @@ func CreateQEMUCommand(board, uuid, biosImage, consolePath, confPath, diskImageP
 	return qmCmd, extraFiles, nil
 }
 
+// memory returns the memory argument for a machine with options, at least
+// def MiB.
+func memory(def int, options MachineOptions) string {
+	return strconv.Itoa(maxInt(def, options.MinMemory))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// QEMUNicArgs returns the QEMU arguments for the additional network
+// interfaces of options. Each is on a hub of its own, without DHCP or any
+// other machine, so it is up to the test to configure them. The arguments
+// go after those of the primary interface, to keep its name.
+func QEMUNicArgs(board string, options MachineOptions) []string {
+	var args []string
+	for i := 1; i <= options.AdditionalNics; i++ {
+		id := fmt.Sprintf("nic%d", i)
+		args = append(args, "-netdev", fmt.Sprintf("hubport,id=%s,hubid=%d", id, i),
+			"-device", Virtio(board, "net", "netdev="+id))
+	}
+	return args
+}
+
+// QEMUFeatures returns the features of QEMU machines of the given board
+// booted with biosImage, apart from private IPs.
+func QEMUFeatures(board, biosImage string) []Feature {
+	features := []Feature{FeatureMultipleDisks, FeatureDiskDeviceOpts, FeatureMultipleNICs, FeatureReboot, FeatureConsoleOutput}
+	bios := strings.ToLower(filepath.Base(biosImage))
+	if board == "arm64-usr" || strings.Contains(bios, "efi") || strings.Contains(bios, "ovmf") {
+		features = append(features, FeatureUEFI)
+	}
+	return features
+}
+
 // The virtio device name differs between machine types but otherwise
 // configuration is the same. Use this to help construct device args.
 func Virtio(board, device, args string) string {
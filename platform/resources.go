@@ -0,0 +1,174 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MachineOptions describe what a machine needs beyond the default machine
+// of its platform. The memory and CPU requirements are honored on every
+// platform, by configuring the machine or by picking a bigger instance
+// type. Additional disks and NICs need FeatureMultipleDisks and
+// FeatureMultipleNICs, and disks with DeviceOpts FeatureDiskDeviceOpts.
+type MachineOptions struct {
+	MinMemory       int    // MiB
+	MinCPUs         int    // virtual CPUs
+	AdditionalDisks []Disk // attached after the boot disk, in order
+	AdditionalNics  int    // network interfaces besides the primary one
+}
+
+type Disk struct {
+	Size        string   // disk image size in bytes, optional suffixes "K", "M", "G", "T" allowed. Incompatible with BackingFile
+	BackingFile string   // raw disk image to use. Incompatible with Size. Only supported on QEMU
+	DeviceOpts  []string // extra options to pass to qemu. "serial=XXXX" makes disks show up as /dev/disk/by-id/virtio-<serial>
+}
+
+// MachineType is an instance type, size, flavor or plan of a cloud
+// platform.
+type MachineType struct {
+	Name   string
+	Memory int // MiB
+	CPUs   int
+}
+
+var (
+	ErrNeedSizeOrFile  = errors.New("Disks need either Size or BackingFile specified")
+	ErrBothSizeAndFile = errors.New("Only one of Size and BackingFile can be specified")
+)
+
+// HasSizeRequirements reports whether o requires a minimum of memory or
+// CPUs.
+func (o MachineOptions) HasSizeRequirements() bool {
+	return o.MinMemory > 0 || o.MinCPUs > 0
+}
+
+// RequiredFeatures returns the features a platform needs to honor o.
+func (o MachineOptions) RequiredFeatures() []Feature {
+	var features []Feature
+	if len(o.AdditionalDisks) > 0 {
+		features = append(features, FeatureMultipleDisks)
+	}
+	for _, disk := range o.AdditionalDisks {
+		if len(disk.DeviceOpts) > 0 {
+			features = append(features, FeatureDiskDeviceOpts)
+			break
+		}
+	}
+	if o.AdditionalNics > 0 {
+		features = append(features, FeatureMultipleNICs)
+	}
+	return features
+}
+
+// Check returns an error if a platform with the given features cannot
+// honor o.
+func (o MachineOptions) Check(features []Feature) error {
+	for _, required := range o.RequiredFeatures() {
+		supported := false
+		for _, f := range features {
+			if f == required {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("platform does not support %v", required)
+		}
+	}
+	return nil
+}
+
+// SizeInGiB returns the size of the disk rounded up to whole GiB, for
+// platforms which allocate disks in GiB.
+func (d Disk) SizeInGiB() (int64, error) {
+	if d.BackingFile != "" {
+		return 0, errors.New("disks with a backing file are only supported on QEMU")
+	}
+	if d.Size == "" {
+		return 0, ErrNeedSizeOrFile
+	}
+
+	num := d.Size
+	var shift uint
+	switch strings.ToUpper(num[len(num)-1:]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	case "T":
+		shift = 40
+	}
+	if shift > 0 {
+		num = num[:len(num)-1]
+	}
+	size, err := strconv.ParseFloat(num, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid disk size %q", d.Size)
+	}
+	return int64(math.Ceil(size * float64(uint64(1)<<shift) / (1 << 30))), nil
+}
+
+func (t MachineType) satisfies(o MachineOptions) bool {
+	return t.Memory >= o.MinMemory && t.CPUs >= o.MinCPUs
+}
+
+// SelectMachineType returns def if machines of that type meet the memory
+// and CPU requirements of o, otherwise the smallest of types which does.
+// def is returned without looking at types if o has no such requirements,
+// and with a warning if it is not one of types since its size is unknown.
+func SelectMachineType(def string, types []MachineType, o MachineOptions) (string, error) {
+	if !o.HasSizeRequirements() {
+		return def, nil
+	}
+
+	known := false
+	for _, t := range types {
+		if t.Name == def {
+			known = true
+			break
+		}
+	}
+	if !known {
+		plog.Warningf("Unknown machine type %v, keeping it without checking for %d MiB of memory and %d CPUs", def, o.MinMemory, o.MinCPUs)
+		return def, nil
+	}
+
+	var best *MachineType
+	for i := range types {
+		t := &types[i]
+		if !t.satisfies(o) {
+			continue
+		}
+		if t.Name == def {
+			return def, nil
+		}
+		if best == nil || t.Memory < best.Memory ||
+			(t.Memory == best.Memory && (t.CPUs < best.CPUs || (t.CPUs == best.CPUs && t.Name < best.Name))) {
+			best = t
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no machine type has at least %d MiB of memory and %d CPUs", o.MinMemory, o.MinCPUs)
+	}
+	plog.Debugf("Using machine type %v instead of %v for %d MiB of memory and %d CPUs", best.Name, def, o.MinMemory, o.MinCPUs)
+	return best.Name, nil
+}
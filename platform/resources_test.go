@@ -0,0 +1,91 @@
+// Copyright 2021 Kinvolk GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"testing"
+)
+
+func TestSelectMachineType(t *testing.T) {
+	types := []MachineType{
+		{Name: "large", Memory: 8192, CPUs: 2},
+		{Name: "2xlarge", Memory: 32768, CPUs: 8},
+		{Name: "xlarge", Memory: 16384, CPUs: 4},
+	}
+	for _, tt := range []struct {
+		def     string
+		options MachineOptions
+		want    string
+	}{
+		{"unknown", MachineOptions{}, "unknown"},
+		{"large", MachineOptions{MinMemory: 8192}, "large"},
+		{"large", MachineOptions{MinMemory: 10000}, "xlarge"},
+		{"large", MachineOptions{MinCPUs: 5}, "2xlarge"},
+		{"2xlarge", MachineOptions{MinCPUs: 4}, "2xlarge"},
+		{"unknown", MachineOptions{MinMemory: 4096}, "unknown"},
+	} {
+		got, err := SelectMachineType(tt.def, types, tt.options)
+		if err != nil {
+			t.Errorf("%v with %+v: %v", tt.def, tt.options, err)
+		} else if got != tt.want {
+			t.Errorf("%v with %+v: expected %v, got %v", tt.def, tt.options, tt.want, got)
+		}
+	}
+
+	if _, err := SelectMachineType("large", types, MachineOptions{MinCPUs: 16}); err == nil {
+		t.Errorf("expected an error for too many CPUs")
+	}
+}
+
+func TestDiskSizeInGiB(t *testing.T) {
+	for size, want := range map[string]int64{
+		"5G":         5,
+		"520M":       1,
+		"1.5G":       2,
+		"1T":         1024,
+		"1073741824": 1,
+	} {
+		got, err := Disk{Size: size}.SizeInGiB()
+		if err != nil {
+			t.Errorf("%v: %v", size, err)
+		} else if got != want {
+			t.Errorf("%v: expected %v GiB, got %v", size, want, got)
+		}
+	}
+
+	for _, d := range []Disk{{Size: "big"}, {Size: "0G"}, {BackingFile: "disk.img"}} {
+		if _, err := d.SizeInGiB(); err == nil {
+			t.Errorf("expected an error for %+v", d)
+		}
+	}
+}
+
+func TestMachineOptionsCheck(t *testing.T) {
+	options := MachineOptions{
+		AdditionalDisks: []Disk{
+			{Size: "1G"},
+			{Size: "1G", DeviceOpts: []string{"serial=secondary"}},
+		},
+	}
+	if err := options.Check([]Feature{FeatureMultipleDisks, FeatureDiskDeviceOpts}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := options.Check([]Feature{FeatureMultipleDisks}); err == nil {
+		t.Errorf("expected an error for disks with DeviceOpts")
+	}
+	if err := options.Check(nil); err == nil {
+		t.Errorf("expected an error for additional disks")
+	}
+}